@@ -1,5 +1,7 @@
 package asciicolors
 
+import "io"
+
 type Color = string
 
 const (
@@ -24,3 +26,33 @@ const (
 	BackgroundCyan    = "\u001b[46m"
 	BackgroundWhite   = "\u001b[47m"
 )
+
+// Stripper removes the escape sequences of what is written
+// through it, for output that isn't going to a terminal
+type Stripper struct {
+	W io.Writer
+	// inside an escape sequence, which may be split between writes
+	escape bool
+}
+
+func (this *Stripper) Write(p []byte) (int, error) {
+	output := make([]byte, 0, len(p))
+	for _, b := range p {
+		switch {
+		case b == '\u001b':
+			this.escape = true
+		case this.escape:
+			// sequences end with a letter, eg: \u001b[31m or \u001b[1A
+			if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') {
+				this.escape = false
+			}
+		default:
+			output = append(output, b)
+		}
+	}
+	_, err := this.W.Write(output)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,300 @@
+// opening books built from recorded games
+package book
+
+import (
+	"chess/game"
+	"chess/game/record"
+	rs "chess/game/result"
+	ifaces "chess/interfaces"
+
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+)
+
+// how many plies of each game go into the book by default
+const DefaultPlies = 16
+
+// Entry is a move seen on a position, the counts are from the
+// point of view of the side that played it
+type Entry struct {
+	From, To game.Point
+
+	Wins   uint32
+	Draws  uint32
+	Losses uint32
+}
+
+// Weight is used to pick moves, a move that never
+// won or drew a game is never picked
+func (this *Entry) Weight() int {
+	return int(2*this.Wins + this.Draws)
+}
+
+func (this *Entry) Games() int {
+	return int(this.Wins + this.Draws + this.Losses)
+}
+
+func (this *Entry) Coord() string {
+	return this.From.String() + this.To.String()
+}
+
+// Book maps position hashes to the moves played on them
+type Book struct {
+	Positions map[uint64][]*Entry
+}
+
+func New() *Book {
+	return &Book{Positions: map[uint64][]*Entry{}}
+}
+
+// Build replays the first plies of each game into a new book
+func Build(games []*record.Game, plies int) (*Book, error) {
+	b := New()
+	for _, rec := range games {
+		err := b.AddGame(rec, plies)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// AddGame replays the first plies of the game into the book,
+// unfinished games are ignored
+func (this *Book) AddGame(rec *record.Game, plies int) error {
+	if rec.Result == rs.InvalidResult {
+		return nil
+	}
+	g, err := game.ParseFEN(rec.Start)
+	if err != nil {
+		return err
+	}
+	for i, mv := range rec.Moves {
+		if i >= plies {
+			break
+		}
+		from, to, ok := game.ParseCoord(mv)
+		if !ok {
+			return errors.New("invalid move in record: " + mv)
+		}
+		this.add(g.Hash(), from, to, outcome(rec.Result, g.BlackTurn))
+		ok, _ = g.Move(from, to)
+		if !ok {
+			return errors.New("illegal move in record: " + mv)
+		}
+	}
+	return nil
+}
+
+// outcome is 1 for a win, 0 for a draw and -1 for a loss
+func outcome(res rs.Result, blackTurn bool) int {
+	switch res {
+	case rs.WhiteWins:
+		if blackTurn {
+			return -1
+		}
+		return 1
+	case rs.BlackWins:
+		if blackTurn {
+			return 1
+		}
+		return -1
+	}
+	return 0
+}
+
+func (this *Book) add(hash uint64, from, to game.Point, out int) {
+	var entry *Entry
+	for _, e := range this.Positions[hash] {
+		if e.From == from && e.To == to {
+			entry = e
+			break
+		}
+	}
+	if entry == nil {
+		entry = &Entry{From: from, To: to}
+		this.Positions[hash] = append(this.Positions[hash], entry)
+	}
+	switch out {
+	case 1:
+		entry.Wins++
+	case 0:
+		entry.Draws++
+	case -1:
+		entry.Losses++
+	}
+}
+
+// Moves returns the legal book moves for the position,
+// hash collisions may otherwise give us garbage
+func (this *Book) Moves(g *game.GameState) []*Entry {
+	output := []*Entry{}
+	for _, e := range this.Positions[g.Hash()] {
+		newG := g.Copy()
+		ok, _ := newG.Move(e.From, e.To)
+		if ok {
+			output = append(output, e)
+		}
+	}
+	return output
+}
+
+// Pick chooses a book move at random, proportionally to its weight
+func (this *Book) Pick(g *game.GameState) (game.Move, bool) {
+	entries := this.Moves(g)
+	total := 0
+	for _, e := range entries {
+		total += e.Weight()
+	}
+	if total == 0 {
+		return game.Move{}, false
+	}
+	n := rand.Intn(total)
+	for _, e := range entries {
+		n -= e.Weight()
+		if n < 0 {
+			return game.Move{
+				Piece: g.Board.AtPos(e.From),
+				From:  e.From,
+				To:    e.To,
+			}, true
+		}
+	}
+	panic("unreachable")
+}
+
+// the file starts with the magic, followed by the number of entries,
+// each entry is the position hash, the origin and destination squares
+// and the win, draw and loss counts, all little endian, sorted by hash
+var magic = [4]byte{'B', 'O', 'O', 'K'}
+
+type fileEntry struct {
+	Hash   uint64
+	From   uint8
+	To     uint8
+	Wins   uint32
+	Draws  uint32
+	Losses uint32
+}
+
+func (this *Book) Save(path string) error {
+	entries := []fileEntry{}
+	for hash, moves := range this.Positions {
+		for _, e := range moves {
+			entries = append(entries, fileEntry{
+				Hash:   hash,
+				From:   uint8(e.From.Column + 8*e.From.Row),
+				To:     uint8(e.To.Column + 8*e.To.Row),
+				Wins:   e.Wins,
+				Draws:  e.Draws,
+				Losses: e.Losses,
+			})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Hash != entries[j].Hash {
+			return entries[i].Hash < entries[j].Hash
+		}
+		if entries[i].From != entries[j].From {
+			return entries[i].From < entries[j].From
+		}
+		return entries[i].To < entries[j].To
+	})
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	binary.Write(w, binary.LittleEndian, magic)
+	binary.Write(w, binary.LittleEndian, uint32(len(entries)))
+	for _, e := range entries {
+		binary.Write(w, binary.LittleEndian, e)
+	}
+	err = w.Flush()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func Load(path string) (*Book, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var m [4]byte
+	err = binary.Read(r, binary.LittleEndian, &m)
+	if err != nil || m != magic {
+		return nil, errors.New(path + " is not an opening book")
+	}
+	var count uint32
+	err = binary.Read(r, binary.LittleEndian, &count)
+	if err != nil {
+		return nil, err
+	}
+	b := New()
+	for i := uint32(0); i < count; i++ {
+		var e fileEntry
+		err = binary.Read(r, binary.LittleEndian, &e)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errors.New(path + " is truncated")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.From >= 64 || e.To >= 64 {
+			return nil, errors.New(path + " is corrupted")
+		}
+		b.Positions[e.Hash] = append(b.Positions[e.Hash], &Entry{
+			From:   game.Point{Row: int(e.From) / 8, Column: int(e.From) % 8},
+			To:     game.Point{Row: int(e.To) / 8, Column: int(e.To) % 8},
+			Wins:   e.Wins,
+			Draws:  e.Draws,
+			Losses: e.Losses,
+		})
+	}
+	return b, nil
+}
+
+// Engine plays from the book while it can, then
+// leaves the rest of the game to the fallback engine
+type Engine struct {
+	Name     string
+	Book     *Book
+	Fallback ifaces.Engine
+}
+
+var _ ifaces.Engine = &Engine{}
+
+func (this *Engine) Play(g *game.GameState) {
+	an := this.Analyse(g, ifaces.Limits{})
+	ok, _ := g.Move(an.Move.From, an.Move.To)
+	if !ok {
+		panic("engine made ilegal move")
+	}
+}
+
+func (this *Engine) Analyse(g *game.GameState, lim ifaces.Limits) ifaces.Analysis {
+	mv, ok := this.Book.Pick(g)
+	if ok {
+		return ifaces.Analysis{Move: mv, PV: []game.Move{mv}}
+	}
+	return this.Fallback.Analyse(g, lim)
+}
+
+func (this *Engine) String() string {
+	return this.Name
+}
+
+func (this *Engine) MaxDepth() int {
+	return ifaces.MaxDepth(this.Fallback)
+}
@@ -0,0 +1,92 @@
+package bot
+
+// the subset of the lichess bot API that we use
+
+type User struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+type Account struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type Variant struct {
+	Key  string `json:"key"`
+	Name string `json:"name,omitempty"`
+}
+
+// TimeControl is in seconds, Type is clock, correspondence or unlimited
+type TimeControl struct {
+	Type      string `json:"type"`
+	Limit     int    `json:"limit,omitempty"`
+	Increment int    `json:"increment,omitempty"`
+}
+
+type Challenge struct {
+	ID          string      `json:"id"`
+	Challenger  User        `json:"challenger"`
+	DestUser    User        `json:"destUser"`
+	Variant     Variant     `json:"variant"`
+	Rated       bool        `json:"rated"`
+	TimeControl TimeControl `json:"timeControl"`
+	Color       string      `json:"color"`
+	InitialFEN  string      `json:"initialFen,omitempty"`
+}
+
+type GameInfo struct {
+	ID    string `json:"gameId"`
+	Color string `json:"color,omitempty"`
+}
+
+// Event comes from /api/stream/event
+type Event struct {
+	Type      string     `json:"type"`
+	Challenge *Challenge `json:"challenge,omitempty"`
+	Game      *GameInfo  `json:"game,omitempty"`
+}
+
+const (
+	EventChallenge         = "challenge"
+	EventChallengeCanceled = "challengeCanceled"
+	EventGameStart         = "gameStart"
+	EventGameFinish        = "gameFinish"
+)
+
+// GameState has the moves from the initial position separated
+// by spaces, and the clocks in milliseconds
+type GameState struct {
+	Type   string `json:"type"`
+	Moves  string `json:"moves"`
+	WTime  int64  `json:"wtime"`
+	BTime  int64  `json:"btime"`
+	WInc   int64  `json:"winc"`
+	BInc   int64  `json:"binc"`
+	Status string `json:"status"`
+	Winner string `json:"winner,omitempty"`
+}
+
+// GameEvent comes from /api/bot/game/stream/{id}, it is either
+// the full game, a state or something we ignore, like chat
+type GameEvent struct {
+	Type       string     `json:"type"`
+	ID         string     `json:"id,omitempty"`
+	Variant    *Variant   `json:"variant,omitempty"`
+	White      *User      `json:"white,omitempty"`
+	Black      *User      `json:"black,omitempty"`
+	InitialFEN string     `json:"initialFen,omitempty"`
+	State      *GameState `json:"state,omitempty"`
+
+	GameState
+}
+
+const (
+	GameFull  = "gameFull"
+	StateType = "gameState"
+)
+
+// StartPos is how the initial position is given for standard games
+const StartPos = "startpos"
+
+const StatusStarted = "started"
@@ -0,0 +1,344 @@
+// Package bot plays on servers implementing the lichess bot API. The
+// rules of our variant are not those of lichess, so only challenges
+// for one of the configured variants are accepted
+package bot
+
+import (
+	"chess/game"
+	ifaces "chess/interfaces"
+
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultVariant is the key we expect servers to give our rules
+const DefaultVariant = "simplified"
+
+type Config struct {
+	// eg: https://lichess.org, without the trailing slash
+	URL   string
+	Token string
+
+	Engine ifaces.Engine
+	// games played at the same time, further challenges are declined
+	MaxGames int
+	// keys of the variants played under our rules
+	Variants []string
+
+	// nil discards the logs
+	Log func(string)
+}
+
+type Client struct {
+	Config
+	account Account
+	http    *http.Client
+
+	// accepted games, true once we follow their stream
+	games map[string]bool
+	wg    sync.WaitGroup
+	sync.Mutex
+}
+
+func New(cfg Config) *Client {
+	if cfg.MaxGames == 0 {
+		cfg.MaxGames = 1
+	}
+	if len(cfg.Variants) == 0 {
+		cfg.Variants = []string{DefaultVariant}
+	}
+	return &Client{
+		Config: cfg,
+		http:   &http.Client{},
+		games:  map[string]bool{},
+	}
+}
+
+func (this *Client) log(format string, args ...any) {
+	if this.Log != nil {
+		this.Log(fmt.Sprintf(format, args...))
+	}
+}
+
+// Run handles the events until the context is done or the stream
+// ends, then waits for the running games to finish
+func (this *Client) Run(ctx context.Context) error {
+	err := this.get(ctx, "/api/account", &this.account)
+	if err != nil {
+		return err
+	}
+	this.log("logged in as %v", this.account.Username)
+	defer this.wg.Wait()
+	return this.stream(ctx, "/api/stream/event", func(data []byte) error {
+		e := Event{}
+		err := json.Unmarshal(data, &e)
+		if err != nil {
+			return err
+		}
+		this.handle(ctx, e)
+		return nil
+	})
+}
+
+func (this *Client) handle(ctx context.Context, e Event) {
+	switch e.Type {
+	case EventChallenge:
+		if e.Challenge == nil || e.Challenge.Challenger.ID == this.account.ID {
+			return
+		}
+		ch := e.Challenge
+		reason := this.declineReason(ch)
+		if reason != "" {
+			this.log("declining %v from %v: %v", ch.ID, ch.Challenger.Name, reason)
+			err := this.post(ctx, "/api/challenge/"+ch.ID+"/decline", url.Values{"reason": {reason}})
+			if err != nil {
+				this.log("declining %v: %v", ch.ID, err)
+			}
+			return
+		}
+		this.log("accepting %v from %v", ch.ID, ch.Challenger.Name)
+		// the game takes its place before it starts, the
+		// challenges until then must see it. Its ID is the
+		// ID of the challenge
+		this.Lock()
+		this.games[ch.ID] = false
+		this.Unlock()
+		err := this.post(ctx, "/api/challenge/"+ch.ID+"/accept", nil)
+		if err != nil {
+			this.log("accepting %v: %v", ch.ID, err)
+			this.Lock()
+			delete(this.games, ch.ID)
+			this.Unlock()
+		}
+	case EventGameStart:
+		if e.Game == nil {
+			return
+		}
+		this.Lock()
+		following := this.games[e.Game.ID]
+		this.games[e.Game.ID] = true
+		this.Unlock()
+		if following {
+			return
+		}
+		this.wg.Add(1)
+		go func() {
+			defer this.wg.Done()
+			err := this.play(ctx, e.Game.ID)
+			if err != nil {
+				this.log("game %v: %v", e.Game.ID, err)
+			}
+			this.Lock()
+			delete(this.games, e.Game.ID)
+			this.Unlock()
+		}()
+	case EventGameFinish:
+		if e.Game != nil {
+			this.log("game %v finished", e.Game.ID)
+			this.Lock()
+			delete(this.games, e.Game.ID)
+			this.Unlock()
+		}
+	}
+}
+
+// declineReason is empty if we can play the challenge, otherwise it
+// is one of the reasons lichess accepts
+func (this *Client) declineReason(ch *Challenge) string {
+	known := false
+	for _, v := range this.Variants {
+		if v == ch.Variant.Key {
+			known = true
+		}
+	}
+	if !known {
+		return "variant"
+	}
+	if ch.InitialFEN != "" && ch.InitialFEN != StartPos {
+		_, err := game.ParseFEN(ch.InitialFEN)
+		if err != nil {
+			return "generic"
+		}
+	}
+	this.Lock()
+	busy := len(this.games) >= this.MaxGames
+	this.Unlock()
+	if busy {
+		return "later"
+	}
+	return ""
+}
+
+// play follows the game stream, moving whenever it's our turn
+func (this *Client) play(ctx context.Context, id string) error {
+	var start *game.GameState
+	black := false
+	// states are sent again for things like draw offers, we
+	// must not answer the same position twice
+	answered := -1
+	return this.stream(ctx, "/api/bot/game/stream/"+id, func(data []byte) error {
+		e := GameEvent{}
+		err := json.Unmarshal(data, &e)
+		if err != nil {
+			return err
+		}
+		var state *GameState
+		switch e.Type {
+		case GameFull:
+			start, err = initialPosition(e.InitialFEN)
+			if err != nil {
+				this.post(ctx, "/api/bot/game/"+id+"/abort", nil)
+				return err
+			}
+			black = e.Black != nil && e.Black.ID == this.account.ID
+			this.log("game %v started as %v", id, colorName(black))
+			state = e.State
+		case StateType:
+			state = &e.GameState
+		default:
+			return nil
+		}
+		if state == nil || start == nil {
+			return nil
+		}
+		if state.Status != StatusStarted {
+			this.log("game %v ended: %v %v", id, state.Status, state.Winner)
+			return io.EOF
+		}
+		g, err := Replay(start, state.Moves)
+		if err != nil {
+			this.post(ctx, "/api/bot/game/"+id+"/resign", nil)
+			return err
+		}
+		if g.IsOver || g.BlackTurn != black || g.Moves.Len() == answered {
+			return nil
+		}
+		answered = g.Moves.Len()
+		an := this.Engine.Analyse(g, limits(state, black))
+		if an.Stopped || an.Move == *game.NullMove {
+			this.post(ctx, "/api/bot/game/"+id+"/resign", nil)
+			return errors.New(this.Engine.String() + " made no move, resigned")
+		}
+		return this.post(ctx, "/api/bot/game/"+id+"/move/"+an.Move.Coord(), nil)
+	})
+}
+
+func colorName(black bool) string {
+	if black {
+		return "black"
+	}
+	return "white"
+}
+
+func initialPosition(fen string) (*game.GameState, error) {
+	if fen == "" || fen == StartPos {
+		return game.InitialGame(game.InitialBoard()), nil
+	}
+	return game.ParseFEN(fen)
+}
+
+// Replay plays the moves, separated by spaces, on a copy of start
+func Replay(start *game.GameState, moves string) (*game.GameState, error) {
+	g := start.Copy()
+	for _, mv := range strings.Fields(moves) {
+		from, to, ok := game.ParseCoord(mv)
+		if ok {
+			ok, _ = g.Move(from, to)
+		}
+		if !ok {
+			return nil, errors.New("illegal move under our rules: " + mv)
+		}
+	}
+	return g, nil
+}
+
+func limits(state *GameState, black bool) ifaces.Limits {
+	t, inc := state.WTime, state.WInc
+	if black {
+		t, inc = state.BTime, state.BInc
+	}
+	return ifaces.Limits{
+		Time:      time.Duration(t) * time.Millisecond,
+		Increment: time.Duration(inc) * time.Millisecond,
+	}
+}
+
+func (this *Client) request(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, this.URL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+this.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	res, err := this.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		res.Body.Close()
+		return nil, fmt.Errorf("%v %v: %v %s", method, path, res.Status, msg)
+	}
+	return res, nil
+}
+
+func (this *Client) get(ctx context.Context, path string, v any) error {
+	res, err := this.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (this *Client) post(ctx context.Context, path string, form url.Values) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	res, err := this.request(ctx, http.MethodPost, path, body)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// stream calls fn for each line of the NDJSON stream, empty lines
+// are keep alives. Returning io.EOF from fn ends the stream cleanly
+func (this *Client) stream(ctx context.Context, path string, fn func([]byte) error) error {
+	res, err := this.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		err = fn([]byte(line))
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return scanner.Err()
+}
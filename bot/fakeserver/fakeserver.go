@@ -0,0 +1,401 @@
+// Package fakeserver stands in for lichess when testing the bot
+// client offline. It keeps everything in memory and only knows the
+// endpoints the client uses, games are played under our rules
+package fakeserver
+
+import (
+	"chess/bot"
+	"chess/game"
+	rs "chess/game/result"
+	ifaces "chess/interfaces"
+
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const Opponent = "opponent"
+
+type Server struct {
+	BotID string
+	// if not nil, plays the moves of the opponent
+	Opponent ifaces.Engine
+	// milliseconds on each clock, zero for unlimited games
+	Time int64
+
+	events     chan bot.Event
+	challenges map[string]*challenge
+	games      map[string]*fakeGame
+	lastID     int
+	sync.Mutex
+}
+
+type challenge struct {
+	bot.Challenge
+	// "", "accepted" or the reason it was declined
+	answer chan string
+}
+
+type fakeGame struct {
+	ID    string
+	Black bool // the bot plays black
+	Start *game.GameState
+	State *game.GameState
+	Moves []string
+
+	Status string
+	Winner string
+	// once the end was sent on the event stream
+	finished bool
+
+	updates chan struct{}
+	sync.Mutex
+}
+
+func New(botID string) *Server {
+	return &Server{
+		BotID:      botID,
+		events:     make(chan bot.Event, 64),
+		challenges: map[string]*challenge{},
+		games:      map[string]*fakeGame{},
+	}
+}
+
+func (this *Server) newID(prefix string) string {
+	this.lastID++
+	return fmt.Sprintf("%v%v", prefix, this.lastID)
+}
+
+// Challenge sends a challenge from the opponent to the bot, the
+// answer arrives on the returned channel: "accepted" or the reason
+// it was declined
+func (this *Server) Challenge(ch bot.Challenge) (string, <-chan string) {
+	this.Lock()
+	defer this.Unlock()
+	ch.ID = this.newID("c")
+	ch.Challenger = bot.User{ID: Opponent, Name: Opponent}
+	ch.DestUser = bot.User{ID: this.BotID, Name: this.BotID}
+	c := &challenge{Challenge: ch, answer: make(chan string, 1)}
+	this.challenges[ch.ID] = c
+	this.events <- bot.Event{Type: bot.EventChallenge, Challenge: &c.Challenge}
+	return ch.ID, c.answer
+}
+
+// Result waits for the game to end, returning its status and winner,
+// the end is on the event stream by then
+func (this *Server) Result(id string, timeout time.Duration) (status, winner string, moves int) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		this.Lock()
+		g, ok := this.games[id]
+		this.Unlock()
+		if ok {
+			g.Lock()
+			status, winner, moves = g.Status, g.Winner, len(g.Moves)
+			finished := g.finished
+			g.Unlock()
+			if finished {
+				return status, winner, moves
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return "timeout", "", moves
+}
+
+// Close ends the event stream
+func (this *Server) Close() {
+	close(this.events)
+}
+
+func (this *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" {
+		httpError(w, http.StatusUnauthorized, "no token")
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/api/account" && r.Method == http.MethodGet:
+		writeJSON(w, bot.Account{ID: this.BotID, Username: this.BotID})
+	case r.URL.Path == "/api/stream/event" && r.Method == http.MethodGet:
+		this.streamEvents(w, r)
+	case len(parts) == 4 && parts[1] == "challenge" && r.Method == http.MethodPost:
+		this.answer(w, r, parts[2], parts[3])
+	case len(parts) == 5 && parts[1] == "bot" && parts[3] == "stream":
+		this.streamGame(w, r, parts[4])
+	case len(parts) == 6 && parts[1] == "bot" && parts[4] == "move" && r.Method == http.MethodPost:
+		this.botMove(w, parts[3], parts[5])
+	case len(parts) == 5 && parts[1] == "bot" && r.Method == http.MethodPost &&
+		(parts[4] == "resign" || parts[4] == "abort"):
+		this.resign(w, parts[3], parts[4])
+	default:
+		httpError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (this *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-this.events:
+			if !ok {
+				return
+			}
+			writeLine(w, flusher, e)
+		}
+	}
+}
+
+func (this *Server) answer(w http.ResponseWriter, r *http.Request, id, action string) {
+	this.Lock()
+	c, ok := this.challenges[id]
+	delete(this.challenges, id)
+	this.Unlock()
+	if !ok {
+		httpError(w, http.StatusNotFound, "no such challenge")
+		return
+	}
+	switch action {
+	case "decline":
+		r.ParseForm()
+		reason := r.Form.Get("reason")
+		if reason == "" {
+			reason = "generic"
+		}
+		c.answer <- reason
+	case "accept":
+		g, err := this.startGame(c)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		c.answer <- "accepted"
+		this.events <- bot.Event{Type: bot.EventGameStart, Game: &bot.GameInfo{ID: g.ID}}
+	default:
+		httpError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, map[string]bool{"ok": true})
+}
+
+func (this *Server) startGame(c *challenge) (*fakeGame, error) {
+	start := game.InitialGame(game.InitialBoard())
+	if c.InitialFEN != "" && c.InitialFEN != bot.StartPos {
+		var err error
+		start, err = game.ParseFEN(c.InitialFEN)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the color is the one asked by the challenger
+	g := &fakeGame{
+		ID:      c.ID,
+		Black:   c.Color == "white",
+		Start:   start,
+		State:   start.Copy(),
+		Status:  bot.StatusStarted,
+		updates: make(chan struct{}, 1),
+	}
+	this.Lock()
+	this.games[g.ID] = g
+	this.Unlock()
+	this.opponentMove(g)
+	return g, nil
+}
+
+func (this *Server) game(id string) *fakeGame {
+	this.Lock()
+	defer this.Unlock()
+	return this.games[id]
+}
+
+func (this *Server) streamGame(w http.ResponseWriter, r *http.Request, id string) {
+	g := this.game(id)
+	if g == nil {
+		httpError(w, http.StatusNotFound, "no such game")
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	white, black := bot.User{ID: this.BotID}, bot.User{ID: Opponent}
+	if g.Black {
+		white, black = black, white
+	}
+	g.Lock()
+	state := this.state(g)
+	g.Unlock()
+	writeLine(w, flusher, bot.GameEvent{
+		Type:       bot.GameFull,
+		ID:         g.ID,
+		Variant:    &bot.Variant{Key: bot.DefaultVariant},
+		White:      &white,
+		Black:      &black,
+		InitialFEN: g.Start.FEN(),
+		State:      &state,
+	})
+	for state.Status == bot.StatusStarted {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-g.updates:
+		}
+		g.Lock()
+		next := this.state(g)
+		g.Unlock()
+		if next == state {
+			continue
+		}
+		state = next
+		writeLine(w, flusher, bot.GameEvent{Type: bot.StateType, GameState: state})
+	}
+}
+
+func (this *Server) state(g *fakeGame) bot.GameState {
+	return bot.GameState{
+		Type:   bot.StateType,
+		Moves:  strings.Join(g.Moves, " "),
+		WTime:  this.Time,
+		BTime:  this.Time,
+		Status: g.Status,
+		Winner: g.Winner,
+	}
+}
+
+func (this *Server) botMove(w http.ResponseWriter, id, uci string) {
+	g := this.game(id)
+	if g == nil {
+		httpError(w, http.StatusNotFound, "no such game")
+		return
+	}
+	g.Lock()
+	if g.Status != bot.StatusStarted || g.State.BlackTurn != g.Black {
+		g.Unlock()
+		httpError(w, http.StatusBadRequest, "not your turn")
+		return
+	}
+	err := g.move(uci)
+	g.Unlock()
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	this.opponentMove(g)
+	writeJSON(w, map[string]bool{"ok": true})
+}
+
+// Move plays for the opponent, when it has no engine
+func (this *Server) Move(id, uci string) error {
+	g := this.game(id)
+	if g == nil {
+		return fmt.Errorf("no such game: %v", id)
+	}
+	g.Lock()
+	defer g.Unlock()
+	if g.Status != bot.StatusStarted || g.State.BlackTurn == g.Black {
+		return fmt.Errorf("not the opponent's turn")
+	}
+	err := g.move(uci)
+	if err == nil {
+		this.finish(g)
+	}
+	return err
+}
+
+func (this *Server) opponentMove(g *fakeGame) {
+	g.Lock()
+	defer g.Unlock()
+	if this.Opponent != nil && g.Status == bot.StatusStarted &&
+		g.State.BlackTurn != g.Black {
+		an := this.Opponent.Analyse(g.State, ifaces.Limits{})
+		g.move(an.Move.Coord())
+	}
+	this.finish(g)
+}
+
+// finish notifies the game stream, and the event stream if it ended
+func (this *Server) finish(g *fakeGame) {
+	select {
+	case g.updates <- struct{}{}:
+	default:
+	}
+	if g.Status != bot.StatusStarted && !g.finished {
+		this.events <- bot.Event{Type: bot.EventGameFinish, Game: &bot.GameInfo{ID: g.ID}}
+		g.finished = true
+	}
+}
+
+func (this *Server) resign(w http.ResponseWriter, id, action string) {
+	g := this.game(id)
+	if g == nil {
+		httpError(w, http.StatusNotFound, "no such game")
+		return
+	}
+	g.Lock()
+	if g.Status == bot.StatusStarted {
+		g.Status = action
+		if action == "resign" {
+			g.Winner = color(!g.Black)
+		} else {
+			g.Status = "aborted"
+		}
+		this.finish(g)
+	}
+	g.Unlock()
+	writeJSON(w, map[string]bool{"ok": true})
+}
+
+func (this *fakeGame) move(uci string) error {
+	from, to, ok := game.ParseCoord(uci)
+	if ok {
+		ok, _ = this.State.Move(from, to)
+	}
+	if !ok {
+		return fmt.Errorf("illegal move: %v", uci)
+	}
+	this.Moves = append(this.Moves, uci)
+	if this.State.IsOver {
+		switch this.State.Result {
+		case rs.WhiteWins:
+			this.Status, this.Winner = "mate", color(false)
+		case rs.BlackWins:
+			this.Status, this.Winner = "mate", color(true)
+		default:
+			this.Status = "draw"
+		}
+	}
+	return nil
+}
+
+func color(black bool) string {
+	if black {
+		return "black"
+	}
+	return "white"
+}
+
+func writeLine(w http.ResponseWriter, flusher http.Flusher, v any) {
+	json.NewEncoder(w).Encode(v)
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func httpError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -3,8 +3,10 @@ package command
 import (
 	ck "chess/command/commandkind"
 	"chess/game"
+	pc "chess/game/piece"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -50,12 +52,19 @@ func (this *Operand) IsLabel() bool {
 	return this.Label != nil
 }
 
+// Parse returns a nil command if the line is empty or only has a comment
 func Parse(cmdstr string) (*Command, *Error) {
 	l := &lexer{
 		Word:  nil,
 		Input: cmdstr,
 	}
-	l.Next()
+	err := l.Next()
+	if err != nil {
+		return nil, err
+	}
+	if l.Word.Kind == _EOF {
+		return nil, nil
+	}
 	cmd, err := parsecmd(l)
 	if err != nil {
 		return nil, err
@@ -312,7 +321,20 @@ func any(st *lexer) (*lexeme, *Error) {
 			nextRune(st)
 			return position(st, r, r2)
 		}
-		return identifier(st), nil
+		id := identifier(st)
+		if peekRune(st) == '(' {
+			return spec(st)
+		}
+		return id, nil
+	}
+	if r == '"' {
+		return quoted(st)
+	}
+	if r == '#' || strings.HasPrefix(st.Input[st.End:], "//") {
+		// comments run to the end of the line
+		st.End = len(st.Input)
+		ignore(st)
+		return &lexeme{Kind: _EOF}, nil
 	}
 	if r == eof {
 		nextRune(st)
@@ -387,8 +409,51 @@ func position(st *lexer, col, row rune) (*lexeme, *Error) {
 	return nil, lexError(st, "invalid position: "+st.Selected())
 }
 
+// quoted text is taken as a label, so that it may
+// contain things like file paths
+func quoted(st *lexer) (*lexeme, *Error) {
+	nextRune(st)
+	acceptUntil(st, "\"\n"+string(eof))
+	if peekRune(st) != '"' {
+		return nil, lexError(st, "unterminated string")
+	}
+	nextRune(st)
+	text := st.Selected()
+	return &lexeme{
+		Kind:  _label,
+		Text:  text[1 : len(text)-1],
+		Range: st.Range(),
+	}, nil
+}
+
+// specs of engines, eg: alphabeta(depth=4,eval=psqt),
+// are taken as a label up to the matching parenthesis
+func spec(st *lexer) (*lexeme, *Error) {
+	depth := 0
+	for {
+		switch nextRune(st) {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '\n', eof:
+			return nil, lexError(st, "unbalanced parenthesis: "+st.Selected())
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	return &lexeme{
+		Kind:  _label,
+		Text:  st.Selected(),
+		Range: st.Range(),
+	}, nil
+}
+
+// identifiers may end in digits, eg: Qd4 or game2
 func identifier(st *lexer) *lexeme {
 	acceptRun(st, letters)
+	acceptRun(st, digits)
 	selected := st.Selected()
 	tp := _label
 	cmdKind := ck.InvalidCommandKind
@@ -430,6 +495,66 @@ func identifier(st *lexer) *lexeme {
 	case "test":
 		tp = _cmd
 		cmdKind = ck.Test
+	case "book":
+		tp = _cmd
+		cmdKind = ck.Book
+	case "undo":
+		tp = _cmd
+		cmdKind = ck.Undo
+	case "redo":
+		tp = _cmd
+		cmdKind = ck.Redo
+	case "takeback":
+		tp = _cmd
+		cmdKind = ck.Takeback
+	case "engine":
+		tp = _cmd
+		cmdKind = ck.Engine
+	case "engines":
+		tp = _cmd
+		cmdKind = ck.Engines
+	case "depth":
+		tp = _cmd
+		cmdKind = ck.Depth
+	case "movetime":
+		tp = _cmd
+		cmdKind = ck.MoveTime
+	case "list":
+		tp = _cmd
+		cmdKind = ck.List
+	case "delete":
+		tp = _cmd
+		cmdKind = ck.Delete
+	case "export":
+		tp = _cmd
+		cmdKind = ck.Export
+	case "import":
+		tp = _cmd
+		cmdKind = ck.Import
+	case "setup":
+		tp = _cmd
+		cmdKind = ck.Setup
+	case "put":
+		tp = _cmd
+		cmdKind = ck.Put
+	case "remove":
+		tp = _cmd
+		cmdKind = ck.Remove
+	case "turn":
+		tp = _cmd
+		cmdKind = ck.Turn
+	case "counter":
+		tp = _cmd
+		cmdKind = ck.Counter
+	case "done":
+		tp = _cmd
+		cmdKind = ck.Done
+	case "cancel":
+		tp = _cmd
+		cmdKind = ck.Cancel
+	case "tournament":
+		tp = _cmd
+		cmdKind = ck.Tournament
 	case "no", "NO":
 		tp = _cmd
 		cmdKind = ck.NO
@@ -526,7 +651,36 @@ func checkCmd(cmd *Command) *Error {
 		return checkCmdProfile(cmd)
 	case ck.Compare:
 		return checkCmdCompare(cmd)
-	case ck.Championship, ck.Quit, ck.Clear, ck.NO, ck.StopProfile, ck.SelfPlay, ck.Test:
+	case ck.Book:
+		return checkCmdBook(cmd)
+	case ck.SelfPlay:
+		return checkCmdSelfPlay(cmd)
+	case ck.Undo, ck.Redo:
+		return checkCmdUndo(cmd)
+	case ck.Engine:
+		return checkCmdEngine(cmd)
+	case ck.Depth:
+		return checkCmdDepth(cmd)
+	case ck.MoveTime:
+		return checkCmdMoveTime(cmd)
+	case ck.Delete:
+		return checkCmdSave(cmd)
+	case ck.Import:
+		return checkCmdImport(cmd)
+	case ck.Export:
+		return checkCmdExport(cmd)
+	case ck.Tournament:
+		return checkCmdTournament(cmd)
+	case ck.Put:
+		return checkCmdPut(cmd)
+	case ck.Remove:
+		return checkCmdRemove(cmd)
+	case ck.Turn:
+		return checkCmdTurn(cmd)
+	case ck.Counter:
+		return checkCmdCounter(cmd)
+	case ck.Championship, ck.Quit, ck.Clear, ck.NO, ck.StopProfile, ck.Test,
+		ck.Takeback, ck.Engines, ck.List, ck.Setup, ck.Done, ck.Cancel:
 		return nil
 	}
 	panic("invalid command")
@@ -542,13 +696,201 @@ func checkMove(cmd *Command) *Error {
 	return checkErr("move <pos> <pos>")
 }
 
+// compare plays a fixed number of games, or a SPRT
+// if it is followed by sprt and optionally its parameters
 func checkCmdCompare(cmd *Command) *Error {
-	if len(cmd.Operands) == 2 &&
-		cmd.Operands[0].IsLabel() &&
+	usage := cmd.Kind.String() + " <engine> <engine> [sprt [parameters]]"
+	if len(cmd.Operands) < 2 || len(cmd.Operands) > 4 {
+		return checkErr(usage)
+	}
+	for _, op := range cmd.Operands {
+		if !op.IsLabel() {
+			return checkErr(usage)
+		}
+	}
+	if len(cmd.Operands) > 2 && *cmd.Operands[2].Label != "sprt" {
+		return checkErr(usage)
+	}
+	return nil
+}
+
+func checkCmdSelfPlay(cmd *Command) *Error {
+	if len(cmd.Operands) > 2 {
+		return checkErr(cmd.Kind.String() + " [engine] [black engine]")
+	}
+	for _, op := range cmd.Operands {
+		if !op.IsLabel() {
+			return checkErr(cmd.Kind.String() + " [engine] [black engine]")
+		}
+	}
+	return nil
+}
+
+func checkCmdEngine(cmd *Command) *Error {
+	if len(cmd.Operands) == 0 {
+		return nil
+	}
+	if len(cmd.Operands) == 1 && cmd.Operands[0].IsLabel() {
+		return nil
+	}
+	return checkErr(cmd.Kind.String() + " [engine]")
+}
+
+func checkCmdDepth(cmd *Command) *Error {
+	if len(cmd.Operands) == 1 && cmd.Operands[0].IsNumber() {
+		return nil
+	}
+	return checkErr(cmd.Kind.String() + " <plies>")
+}
+
+// movetime takes a number and an optional unit, eg: 2s, 500ms
+func checkCmdMoveTime(cmd *Command) *Error {
+	if len(cmd.Operands) == 1 && cmd.Operands[0].IsNumber() {
+		return nil
+	}
+	if len(cmd.Operands) == 2 && cmd.Operands[0].IsNumber() &&
 		cmd.Operands[1].IsLabel() {
+		_, ok := TimeUnits[*cmd.Operands[1].Label]
+		if ok {
+			return nil
+		}
+	}
+	return checkErr(cmd.Kind.String() + " <number>[ms|s|m]")
+}
+
+// TimeUnits are the units accepted by movetime
+var TimeUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+}
+
+func checkCmdUndo(cmd *Command) *Error {
+	if len(cmd.Operands) == 0 {
 		return nil
 	}
-	return checkErr(cmd.Kind.String() + " <label> <label>")
+	if len(cmd.Operands) == 1 && cmd.Operands[0].IsNumber() &&
+		*cmd.Operands[0].Number > 0 {
+		return nil
+	}
+	return checkErr(cmd.Kind.String() + " [plies]")
+}
+
+func checkCmdPut(cmd *Command) *Error {
+	if len(cmd.Operands) == 0 {
+		return checkErr(cmd.Kind.String() + " <piece><pos>... (eg: Qd4 ke8)")
+	}
+	for _, op := range cmd.Operands {
+		if !op.IsLabel() {
+			return checkErr(cmd.Kind.String() + " <piece><pos>... (eg: Qd4 ke8)")
+		}
+		_, _, ok := ParsePlacement(*op.Label)
+		if !ok {
+			return &Error{message: "invalid placement: " + *op.Label + ", expected a piece and a position (eg: Qd4 ke8)"}
+		}
+	}
+	return nil
+}
+
+// ParsePlacement reads a piece as in FEN followed by its position, eg: Qd4
+func ParsePlacement(s string) (pc.Piece, game.Point, bool) {
+	if len(s) != 3 {
+		return pc.InvalidPiece, game.Point{}, false
+	}
+	piece, ok := game.PieceFromRune(rune(s[0]))
+	if !ok {
+		return pc.InvalidPiece, game.Point{}, false
+	}
+	pos, ok := game.ParsePoint(s[1:])
+	if !ok {
+		return pc.InvalidPiece, game.Point{}, false
+	}
+	return piece, pos, true
+}
+
+func checkCmdRemove(cmd *Command) *Error {
+	if len(cmd.Operands) == 0 {
+		return checkErr(cmd.Kind.String() + " <pos>...")
+	}
+	for _, op := range cmd.Operands {
+		if !op.IsPosition() {
+			return checkErr(cmd.Kind.String() + " <pos>...")
+		}
+	}
+	return nil
+}
+
+func checkCmdTurn(cmd *Command) *Error {
+	if len(cmd.Operands) == 1 && cmd.Operands[0].IsLabel() {
+		switch *cmd.Operands[0].Label {
+		case "white", "black":
+			return nil
+		}
+	}
+	return checkErr(cmd.Kind.String() + " white|black")
+}
+
+// the position is a draw once the counter reaches 50
+func checkCmdCounter(cmd *Command) *Error {
+	if len(cmd.Operands) == 0 {
+		return nil
+	}
+	if len(cmd.Operands) == 1 && cmd.Operands[0].IsNumber() &&
+		*cmd.Operands[0].Number < 50 {
+		return nil
+	}
+	return checkErr(cmd.Kind.String() + " [moves since the last capture, less than 50]")
+}
+
+func checkCmdTournament(cmd *Command) *Error {
+	usage := cmd.Kind.String() + " roundrobin|gauntlet|swiss <games> <engine> <engine>..., or resume [file]"
+	if len(cmd.Operands) > 0 && cmd.Operands[0].IsLabel() &&
+		*cmd.Operands[0].Label == "resume" {
+		if len(cmd.Operands) > 2 ||
+			(len(cmd.Operands) == 2 && !cmd.Operands[1].IsLabel()) {
+			return checkErr(usage)
+		}
+		return nil
+	}
+	if len(cmd.Operands) < 4 ||
+		!cmd.Operands[0].IsLabel() ||
+		!cmd.Operands[1].IsNumber() {
+		return checkErr(usage)
+	}
+	switch *cmd.Operands[0].Label {
+	case "roundrobin", "gauntlet", "swiss":
+	default:
+		return checkErr(usage)
+	}
+	if *cmd.Operands[1].Number == 0 || *cmd.Operands[1].Number%2 != 0 {
+		return &Error{message: "the number of games of each pairing must be even"}
+	}
+	for _, op := range cmd.Operands[2:] {
+		if !op.IsLabel() {
+			return checkErr(usage)
+		}
+	}
+	return nil
+}
+
+func checkCmdBook(cmd *Command) *Error {
+	if len(cmd.Operands) >= 2 && len(cmd.Operands) <= 3 &&
+		cmd.Operands[0].IsLabel() &&
+		cmd.Operands[1].IsLabel() {
+		if len(cmd.Operands) == 2 ||
+			isValidLayout(*cmd.Operands[2].Label) {
+			return nil
+		}
+	}
+	return checkErr(cmd.Kind.String() + " <games> <book> [standard|shuffled]")
+}
+
+func isValidLayout(s string) bool {
+	switch s {
+	case "standard", "shuffled":
+		return true
+	}
+	return false
 }
 
 func checkCmdSave(cmd *Command) *Error {
@@ -558,6 +900,25 @@ func checkCmdSave(cmd *Command) *Error {
 	return checkErr(cmd.Kind.String() + " <label>")
 }
 
+func checkCmdImport(cmd *Command) *Error {
+	if len(cmd.Operands) == 1 && cmd.Operands[0].IsLabel() {
+		return nil
+	}
+	return checkErr(cmd.Kind.String() + " <file>")
+}
+
+func checkCmdExport(cmd *Command) *Error {
+	if len(cmd.Operands) == 0 {
+		return checkErr(cmd.Kind.String() + " <file> [label...]")
+	}
+	for _, op := range cmd.Operands {
+		if !op.IsLabel() {
+			return checkErr(cmd.Kind.String() + " <file> [label...]")
+		}
+	}
+	return nil
+}
+
 func checkCmdProfile(cmd *Command) *Error {
 	if len(cmd.Operands) == 1 && cmd.Operands[0].IsLabel() {
 		return nil
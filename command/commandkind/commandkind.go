@@ -28,6 +28,46 @@ func (this CommandKind) String() string {
 		return "championship"
 	case Test:
 		return "test"
+	case Book:
+		return "book"
+	case Undo:
+		return "undo"
+	case Redo:
+		return "redo"
+	case Takeback:
+		return "takeback"
+	case Engine:
+		return "engine"
+	case Engines:
+		return "engines"
+	case Depth:
+		return "depth"
+	case MoveTime:
+		return "movetime"
+	case List:
+		return "list"
+	case Delete:
+		return "delete"
+	case Export:
+		return "export"
+	case Import:
+		return "import"
+	case Setup:
+		return "setup"
+	case Put:
+		return "put"
+	case Remove:
+		return "remove"
+	case Turn:
+		return "turn"
+	case Counter:
+		return "counter"
+	case Done:
+		return "done"
+	case Cancel:
+		return "cancel"
+	case Tournament:
+		return "tournament"
 	}
 	return "???"
 }
@@ -49,4 +89,30 @@ const (
 
 	Profile
 	StopProfile
+
+	Book
+
+	Undo
+	Redo
+	Takeback
+
+	Engine
+	Engines
+	Depth
+	MoveTime
+
+	List
+	Delete
+	Export
+	Import
+
+	Setup
+	Put
+	Remove
+	Turn
+	Counter
+	Done
+	Cancel
+
+	Tournament
 )
@@ -0,0 +1,146 @@
+package comparisons
+
+import (
+	"chess/game"
+	rs "chess/game/result"
+	ifaces "chess/interfaces"
+
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Adjudication ends the games whose outcome is clear from the scores of
+// the engines. A move is made by both sides, zero disables each rule
+type Adjudication struct {
+	// a side resigns if both engines score the game
+	// below -Resign for it during ResignMoves moves
+	Resign      int
+	ResignMoves int
+	// the game is drawn if both engines score it within Draw
+	// during DrawMoves moves, counting from move DrawAfter
+	Draw      int
+	DrawMoves int
+	DrawAfter int
+	// the game is drawn after this many moves
+	MaxMoves int
+}
+
+func (this Adjudication) IsZero() bool {
+	return this == Adjudication{}
+}
+
+// ParseAdjudication reads the rules, the missing ones are disabled,
+// eg: "resign=800,resignmoves=4,draw=20,drawmoves=10,drawafter=40,maxmoves=200"
+func ParseAdjudication(s string) (Adjudication, error) {
+	output := Adjudication{}
+	if strings.TrimSpace(s) == "" {
+		return output, nil
+	}
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return output, errors.New("expected key=value: " + field)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return output, errors.New("invalid " + key + ": " + value)
+		}
+		switch key {
+		case "resign":
+			output.Resign = n
+		case "resignmoves":
+			output.ResignMoves = n
+		case "draw":
+			output.Draw = n
+		case "drawmoves":
+			output.DrawMoves = n
+		case "drawafter":
+			output.DrawAfter = n
+		case "maxmoves":
+			output.MaxMoves = n
+		default:
+			return output, errors.New("unknown adjudication rule: " + key)
+		}
+	}
+	if (output.Resign == 0) != (output.ResignMoves == 0) {
+		return output, errors.New("resign and resignmoves go together")
+	}
+	if output.DrawMoves == 0 && (output.Draw != 0 || output.DrawAfter != 0) {
+		return output, errors.New("draw and drawafter need drawmoves")
+	}
+	return output, nil
+}
+
+func (this Adjudication) String() string {
+	fields := []string{}
+	add := func(key string, n int) {
+		if n != 0 {
+			fields = append(fields, fmt.Sprintf("%v=%v", key, n))
+		}
+	}
+	add("resign", this.Resign)
+	add("resignmoves", this.ResignMoves)
+	add("draw", this.Draw)
+	add("drawmoves", this.DrawMoves)
+	add("drawafter", this.DrawAfter)
+	add("maxmoves", this.MaxMoves)
+	return strings.Join(fields, ",")
+}
+
+// adjudicator follows the scores of a game
+type adjudicator struct {
+	Adjudication
+	// plies in a row scored as lost, positive if
+	// white is winning, and scored as drawn
+	winning int
+	drawn   int
+}
+
+// check is called after each move, with the analysis that made it,
+// and returns the result if the game should end
+func (this *adjudicator) check(g *game.GameState, an ifaces.Analysis) (rs.Result, string, bool) {
+	plies := g.Moves.Len()
+	if !an.Scored {
+		this.winning, this.drawn = 0, 0
+	} else {
+		switch {
+		case this.ResignMoves == 0:
+		case an.Score >= this.Resign && this.winning >= 0:
+			this.winning++
+		case an.Score >= this.Resign:
+			this.winning = 1
+		case an.Score <= -this.Resign && this.winning <= 0:
+			this.winning--
+		case an.Score <= -this.Resign:
+			this.winning = -1
+		default:
+			this.winning = 0
+		}
+		if plies >= 2*this.DrawAfter && abs(an.Score) <= this.Draw {
+			this.drawn++
+		} else {
+			this.drawn = 0
+		}
+	}
+	switch {
+	case this.ResignMoves > 0 && this.winning >= 2*this.ResignMoves:
+		return rs.WhiteWins, "Black resigned by adjudication", true
+	case this.ResignMoves > 0 && this.winning <= -2*this.ResignMoves:
+		return rs.BlackWins, "White resigned by adjudication", true
+	case this.DrawMoves > 0 && this.drawn >= 2*this.DrawMoves:
+		return rs.Draw, "drawn by adjudication", true
+	case this.MaxMoves > 0 && plies >= 2*this.MaxMoves:
+		return rs.Draw, "drawn by adjudication, the game reached " +
+			strconv.Itoa(this.MaxMoves) + " moves", true
+	}
+	return rs.InvalidResult, "", false
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
@@ -2,23 +2,82 @@ package comparisons
 
 import (
 	"chess/game"
+	"chess/game/clock"
+	"chess/game/record"
 	rs "chess/game/result"
 	ifaces "chess/interfaces"
+	movegen "chess/movegen/basic"
+	"chess/stream"
 
 	colors "chess/asciicolors"
 
 	"fmt"
+	"math/rand"
 	"runtime"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Config holds the settings of a match
+type Config struct {
+	// number of games, must be even so that each
+	// opening is played with both colors
+	Games int
+	// zero means untimed games
+	Control clock.Control
+	// if not nil, the moves of every game are published here
+	Stream *stream.Broker
+	// don't draw the progress bar
+	Quiet bool
+	// played in order, each with both colours, and again from the
+	// first if there are more games. If empty, the boards are shuffled
+	Openings []Opening
+	// of the shuffled boards, zero shuffles them at random
+	Seed int64
+	// zero plays every game to the end
+	Adjudication Adjudication
+	// games played at once, zero is one for each CPU
+	Workers int
+	// closing it stops handing out games, those
+	// being played are finished and counted
+	Stop <-chan struct{}
+}
+
+func (this Config) workers() int {
+	if this.Workers > 0 {
+		return this.Workers
+	}
+	return runtime.NumCPU()
+}
+
+func (this Config) stopped() bool {
+	select {
+	case <-this.Stop:
+		return true
+	default:
+		return false
+	}
+}
+
 func Compare(a, b ifaces.Engine, amount int) FightResult {
-	if amount%2 != 0 {
+	return Run(a, b, Config{Games: amount})
+}
+
+func Run(a, b ifaces.Engine, cfg Config) FightResult {
+	if cfg.Games%2 != 0 {
 		panic("comparison number must be even")
 	}
-	dwl := newDuelWorkList(a, b, amount)
-	results := dwl.Start(runtime.NumCPU())
+	dwl := newDuelWorkList(makeDuels(a, b, cfg), cfg)
+	dwl.Start(cfg.workers())
+	results := dwl.GetResults()
+	dwl.Stop()
+	return tally(a, b, results)
+}
+
+// tally sums the results of the duels between a and b
+func tally(a, b ifaces.Engine, results []FightResult) FightResult {
 	output := FightResult{
 		White: &EngineScore{
 			Eng:     a,
@@ -33,7 +92,11 @@ func Compare(a, b ifaces.Engine, amount int) FightResult {
 	}
 	whiteTimes := []time.Duration{}
 	blackTimes := []time.Duration{}
+	wins, draws, losses := 0, 0, 0
 	for _, res := range results {
+		output.Games = append(output.Games, res.Games...)
+		output.Details = append(output.Details, res.Details...)
+		output.Crashes = append(output.Crashes, res.Crashes...)
 		if res.White.Eng == output.White.Eng {
 			output.White.Score += res.White.Score
 			output.Black.Score += res.Black.Score
@@ -45,80 +108,167 @@ func Compare(a, b ifaces.Engine, amount int) FightResult {
 			whiteTimes = append(whiteTimes, res.Black.Average)
 			blackTimes = append(blackTimes, res.White.Average)
 		}
+		// each duel is a single game
+		switch score(res, a) {
+		case 1:
+			wins++
+		case 0.5:
+			draws++
+		default:
+			losses++
+		}
 	}
 	output.White.Average = average(whiteTimes)
 	output.Black.Average = average(blackTimes)
+	output.Stats = NewStats(wins, draws, losses)
 	return output
 }
 
-func newDuelWorkList(a, b ifaces.Engine, amount int) *duelWorkList {
-	duels := makeDuels(a, b, amount)
+// score is what eng made in the duel
+func score(res FightResult, eng ifaces.Engine) float64 {
+	if res.White.Eng == eng {
+		return res.White.Score
+	}
+	return res.Black.Score
+}
+
+func newDuelWorkList(duels []*Duel, cfg Config) *duelWorkList {
 	return &duelWorkList{
-		queue: duels,
-		top:   len(duels) - 1,
-		out:   make(chan FightResult),
-		Mutex: sync.Mutex{},
+		queue:   duels,
+		out:     make(chan FightResult),
+		quiet:   cfg.Quiet,
+		stop:    cfg.Stop,
+		done:    make(chan struct{}),
+		cleared: make(chan struct{}),
+		Mutex:   sync.Mutex{},
 	}
 }
 
 type duelWorkList struct {
 	queue []*Duel
-	top   int
+	// index of the next duel to be played
+	next int
+	// if not nil, makes more duels when the queue runs
+	// out, nothing more is played if it returns none
+	more    func() []*Duel
+	stopped bool
+	// if not nil, called by the workers as each duel ends
+	finished func(duel *Duel, res FightResult, taken time.Duration)
+
 	out   chan FightResult
+	quiet bool
+	stop  <-chan struct{}
+	// the workers that are running
+	workers sync.WaitGroup
+	// shown instead of the progress bar if not empty
+	status string
+	// closed when the results are in, and when the bar is gone
+	done    chan struct{}
+	cleared chan struct{}
 	sync.Mutex
 }
 
 func (this *duelWorkList) Pop() *Duel {
 	this.Mutex.Lock()
-	if this.top < 0 {
+	defer this.Mutex.Unlock()
+	if !this.stopped && this.next >= len(this.queue) && this.more != nil {
+		this.queue = append(this.queue, this.more()...)
+	}
+	if this.stopped || this.next >= len(this.queue) {
 		return nil
 	}
-	out := this.queue[this.top]
-	this.top--
-	this.Mutex.Unlock()
+	out := this.queue[this.next]
+	this.next++
 	return out
 }
 
+// Drain stops handing out duels, returning how many were handed out,
+// the results of all of them will still arrive
+func (this *duelWorkList) Drain() int {
+	this.Mutex.Lock()
+	defer this.Mutex.Unlock()
+	this.stopped = true
+	return this.next
+}
+
+func (this *duelWorkList) SetStatus(s string) {
+	this.Mutex.Lock()
+	this.status = s
+	this.Mutex.Unlock()
+}
+
 func (this *duelWorkList) Out(fr FightResult) {
 	this.out <- fr
 }
 
+// GetResults waits for every duel, or if it is stopped,
+// for the duels that were handed out
 func (this *duelWorkList) GetResults() []FightResult {
-	output := make([]FightResult, len(this.queue))
-	for i := range this.queue {
-		output[i] = <-this.out
+	output := []FightResult{}
+	total := len(this.queue)
+	stop := this.stop
+	for len(output) < total {
+		select {
+		case res := <-this.out:
+			output = append(output, res)
+		case <-stop:
+			total = this.Drain()
+			stop = nil
+		}
 	}
 	return output
 }
 
-func (this *duelWorkList) Start(procs int) []FightResult {
+func (this *duelWorkList) Start(procs int) {
+	this.workers.Add(procs)
 	for i := 0; i < procs; i++ {
 		go work(this)
 	}
-	ticker := time.NewTicker(200 * time.Millisecond)
-	go this.progressBarUwU(ticker)
-	results := this.GetResults()
-	ticker.Stop()
-	fmt.Print("\033[1A\033[K")
-	return results
+	if this.quiet {
+		close(this.cleared)
+		return
+	}
+	go this.progressBarUwU()
+}
+
+// Stop removes the progress bar once the results are
+// in, and waits for the workers to return
+func (this *duelWorkList) Stop() {
+	this.Drain()
+	close(this.done)
+	<-this.cleared
+	this.workers.Wait()
 }
 
-func (this *duelWorkList) progressBarUwU(ticker *time.Ticker) {
+func (this *duelWorkList) progressBarUwU() {
+	defer close(this.cleared)
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
 	fmt.Println()
 	for {
-		_, ok := <-ticker.C
-		if !ok {
-			break
+		select {
+		case <-this.done:
+			fmt.Print("\033[1A\033[K")
+			return
+		case <-ticker.C:
 		}
-		processed := len(this.queue) - (this.top + 1)
+		this.Mutex.Lock()
+		processed := this.next
 		total := len(this.queue)
-		bar := makebar(processed, total)
-		fmt.Printf("\033[1A\033[K%v %v / %v                       \n", bar, processed, total)
+		line := this.status
+		this.Mutex.Unlock()
+		if line == "" {
+			line = fmt.Sprintf("%v %v / %v", makebar(processed, total), processed, total)
+		}
+		fmt.Printf("\033[1A\033[K%v                       \n", line)
 	}
 }
 
 func makebar(processed, total int) string {
-	bars := (processed * 20 / total)
+	bars := 0
+	if total > 0 {
+		bars = processed * 20 / total
+	}
 	output := "|" + colors.BackgroundGreen
 	for i := 0; i < bars; i++ {
 		output += " "
@@ -131,43 +281,126 @@ func makebar(processed, total int) string {
 }
 
 func work(workList *duelWorkList) {
+	defer workList.workers.Done()
 	job := workList.Pop()
 	for job != nil {
+		start := time.Now()
 		result := job.run()
+		if workList.finished != nil {
+			workList.finished(job, result, time.Since(start))
+		}
 		workList.Out(result)
 		job = workList.Pop()
 	}
 }
 
 type Duel struct {
-	White ifaces.Engine
-	Black ifaces.Engine
-	Board game.Board
+	White   ifaces.Engine
+	Black   ifaces.Engine
+	Board   game.Board
+	Control clock.Control
+	// the board was shuffled from it
+	Seed int64
+	// if not nil, played instead of the board
+	Opening      *Opening
+	Adjudication Adjudication
+
+	// identify the game on the stream
+	ID     string
+	Match  string
+	Stream *stream.Broker
+	// identifies the game in a tournament
+	Key string
+
+	clock       *clock.Clock
+	adjudicator *adjudicator
+	moves       []MoveStat
 }
 
-func (this *Duel) run() FightResult {
-	white := &EngineScore{
-		Eng:   this.White,
-		Score: 0,
+// run plays the game, if an engine panics it loses the game
+// and the stack trace is kept in FightResult.Crashes. So is the
+// error of an opening that can't be played, the game is drawn
+func (this *Duel) run() (output FightResult) {
+	if !this.Control.IsZero() {
+		this.clock = clock.New(this.Control)
 	}
-	black := &EngineScore{
-		Eng:   this.Black,
-		Score: 0,
+	if !this.Adjudication.IsZero() {
+		this.adjudicator = &adjudicator{Adjudication: this.Adjudication}
 	}
-	whiteTimes := []time.Duration{}
-	blackTimes := []time.Duration{}
-	g := game.InitialGame(&this.Board)
+	start := game.InitialGame(&this.Board)
+	g := start.Copy()
+	if this.Opening != nil {
+		openingStart, openingEnd, err := this.Opening.Play()
+		if err != nil {
+			// nobody is to blame, the game ends before it starts
+			opening := this.Opening
+			this.Opening = nil
+			g.End(rs.Draw, "the opening can't be played: "+err.Error())
+			output = this.finish(start, g)
+			output.Crashes = []string{fmt.Sprintf("game %v, %v vs %v, the opening %v %v can't be played: %v",
+				this.ID, this.White, this.Black, opening.FEN, opening.Moves, err)}
+			return output
+		}
+		start, g = openingStart, openingEnd
+	}
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		side := sideOf(g.BlackTurn)
+		if !g.IsOver {
+			g.End(lossOf(g.BlackTurn), fmt.Sprintf("%v crashed: %v", side, r))
+		}
+		output = this.finish(start, g)
+		output.Crashes = []string{fmt.Sprintf("game %v, %v vs %v, %v crashed: %v\n%s",
+			this.ID, this.White, this.Black, side, r, debug.Stack())}
+	}()
+	this.publish(stream.Event{Kind: stream.Start, FEN: g.FEN()})
 	for !g.IsOver {
 		if g.BlackTurn {
-			start := time.Now()
-			black.Eng.Play(g)
-			blackTimes = append(blackTimes, time.Since(start))
+			this.play(this.Black, g)
+		} else {
+			this.play(this.White, g)
+		}
+	}
+	return this.finish(start, g)
+}
+
+func sideOf(black bool) string {
+	if black {
+		return "Black"
+	}
+	return "White"
+}
+
+// lossOf is the result of the game lost by the side
+func lossOf(black bool) rs.Result {
+	if black {
+		return rs.WhiteWins
+	}
+	return rs.BlackWins
+}
+
+// finish scores the game that went from start to g
+func (this *Duel) finish(start, g *game.GameState) FightResult {
+	white := &EngineScore{Eng: this.White}
+	black := &EngineScore{Eng: this.Black}
+	whiteTimes := []time.Duration{}
+	blackTimes := []time.Duration{}
+	for _, mv := range this.moves {
+		if mv.Black {
+			blackTimes = append(blackTimes, mv.Time)
 		} else {
-			start := time.Now()
-			white.Eng.Play(g)
-			whiteTimes = append(whiteTimes, time.Since(start))
+			whiteTimes = append(whiteTimes, mv.Time)
 		}
 	}
+	this.publish(stream.Event{
+		Kind:   stream.End,
+		FEN:    g.FEN(),
+		Result: g.Result,
+		Reason: g.Reason,
+	})
 	switch g.Result {
 	case rs.Draw:
 		white.Score += 0.5
@@ -179,12 +412,98 @@ func (this *Duel) run() FightResult {
 	}
 	white.Average = average(whiteTimes)
 	black.Average = average(blackTimes)
-	return FightResult{white, black}
+	rec := record.New(this.White.String(), this.Black.String(), start, g)
+	details := &GameDetails{Seed: this.Seed, Moves: this.moves}
+	if this.Opening != nil {
+		details.Seed = 0
+		details.OpeningMoves = len(this.Opening.Moves)
+	}
+	return FightResult{
+		White:   white,
+		Black:   black,
+		Games:   []*record.Game{rec},
+		Details: []*GameDetails{details},
+	}
+}
+
+// play makes the engine move, a timed game ends if its
+// flag falls before the move is made
+func (this *Duel) play(eng ifaces.Engine, g *game.GameState) {
+	black := g.BlackTurn
+	lim := ifaces.Limits{}
+	if this.clock != nil {
+		lim.Time = this.clock.Remaining(black)
+		lim.Increment = this.Control.Increment
+		lim.MovesToGo = this.clock.MovesToGo(black)
+		this.clock.Start(black)
+	}
+	legal := len(movegen.ConsumeAll(movegen.NewMoveGenerator(g.Copy())))
+	phase := phaseOf(g)
+	start := time.Now()
+	// on a copy, so that an engine that panics
+	// midway leaves the game as it was
+	an := eng.Analyse(g.Copy(), lim)
+	taken := time.Since(start)
+	if this.clock != nil && !this.clock.Stop() {
+		g.End(clock.Loss(black))
+		return
+	}
+	// an engine that can't move loses the game
+	if an.Move == *game.NullMove {
+		g.End(lossOf(black), sideOf(black)+" made no move")
+		return
+	}
+	ok, _ := g.Move(an.Move.From, an.Move.To)
+	if !ok {
+		g.End(lossOf(black), sideOf(black)+" made an illegal move: "+an.Move.Coord())
+		return
+	}
+	this.moves = append(this.moves, MoveStat{
+		Move:  an.Move.Coord(),
+		Black: black,
+		Time:  taken,
+		Nodes: an.Nodes,
+		Legal: legal,
+		Phase: phase,
+	})
+	this.publish(stream.Event{
+		Kind:  stream.Move,
+		FEN:   g.FEN(),
+		Ply:   g.Moves.Len(),
+		Move:  an.Move.Coord(),
+		Score: an.Score,
+	})
+	if this.adjudicator != nil && !g.IsOver {
+		res, reason, ok := this.adjudicator.check(g, an)
+		if ok {
+			g.End(res, reason)
+		}
+	}
+}
+
+func (this *Duel) publish(e stream.Event) {
+	if this.Stream == nil {
+		return
+	}
+	e.Game = this.ID
+	e.Match = this.Match
+	e.White = this.White.String()
+	e.Black = this.Black.String()
+	this.Stream.Publish(e)
 }
 
 type FightResult struct {
 	White *EngineScore
 	Black *EngineScore
+
+	Games []*record.Game
+	// in the order of Games
+	Details []*GameDetails
+	// stack traces of the engines that panicked, each lost its game
+	Crashes []string
+
+	// from the point of view of White, only set by Run
+	Stats Stats
 }
 
 func (this FightResult) String() string {
@@ -193,26 +512,113 @@ func (this FightResult) String() string {
 		this.Black.Score, this.Black.Eng.String(), this.Black.Average)
 }
 
+// GameDetails are what the record of a game doesn't keep
+type GameDetails struct {
+	// the board was shuffled from it, zero if it wasn't
+	Seed int64
+	// the first moves of the record are of the opening
+	OpeningMoves int
+	// made by the engines, in order
+	Moves []MoveStat
+}
+
+// MoveStat is a move made by an engine
+type MoveStat struct {
+	Move  string        `json:"move"`
+	Black bool          `json:"black,omitempty"`
+	Time  time.Duration `json:"time"`
+	// zero if the engine doesn't tell
+	Nodes int `json:"nodes,omitempty"`
+	// moves the engine could choose from
+	Legal int   `json:"legal"`
+	Phase Phase `json:"phase"`
+}
+
 type EngineScore struct {
 	Eng     ifaces.Engine
 	Score   float64
 	Average time.Duration
 }
 
-func makeDuels(A, B ifaces.Engine, number int) []*Duel {
-	duels := make([]*Duel, number)
-	for i := 0; i < number; i += 2 {
-		board := game.ShuffledBoard()
-		duels[i] = &Duel{
-			White: A,
-			Black: B,
-			Board: *board,
-		}
-		duels[i+1] = &Duel{
-			White: B,
-			Black: A,
-			Board: *board,
-		}
+// matches counts the matches played, to name their games
+var matches int64
+
+// duelMaker pairs the engines on the same openings, each
+// played twice with colours reversed
+type duelMaker struct {
+	a, b  ifaces.Engine
+	cfg   Config
+	match int64
+	name  string
+	made  int
+	pairs int
+	// no more pairs are made after this many duels, zero is no limit
+	max int
+	// the seeds of the openings come from it
+	seeds *rand.Rand
+}
+
+func newDuelMaker(a, b ifaces.Engine, cfg Config) *duelMaker {
+	match := atomic.AddInt64(&matches, 1)
+	output := &duelMaker{
+		a:     a,
+		b:     b,
+		cfg:   cfg,
+		match: match,
+		name:  fmt.Sprintf("%v: %v vs %v", match, a, b),
+	}
+	if cfg.Seed != 0 {
+		output.seeds = rand.New(rand.NewSource(cfg.Seed))
+	}
+	return output
+}
+
+func (this *duelMaker) pair() []*Duel {
+	if this.max > 0 && this.made >= this.max {
+		return nil
+	}
+	if len(this.cfg.Openings) > 0 {
+		op := &this.cfg.Openings[this.pairs%len(this.cfg.Openings)]
+		this.pairs++
+		first := this.duel(this.a, this.b, game.InitialBoard(), 0)
+		second := this.duel(this.b, this.a, game.InitialBoard(), 0)
+		first.Opening, second.Opening = op, op
+		return []*Duel{first, second}
+	}
+	var seed int64
+	if this.seeds != nil {
+		seed = this.seeds.Int63()
+	} else {
+		seed = rand.Int63()
+	}
+	board := game.ShuffledBoardFrom(rand.New(rand.NewSource(seed)))
+	return []*Duel{
+		this.duel(this.a, this.b, board, seed),
+		this.duel(this.b, this.a, board, seed),
+	}
+}
+
+func (this *duelMaker) duel(white, black ifaces.Engine, board *game.Board, seed int64) *Duel {
+	this.made++
+	return &Duel{
+		White:        white,
+		Black:        black,
+		Board:        *board,
+		Control:      this.cfg.Control,
+		Seed:         seed,
+		Adjudication: this.cfg.Adjudication,
+
+		ID:     fmt.Sprintf("%v.%v", this.match, this.made),
+		Match:  this.name,
+		Stream: this.cfg.Stream,
+	}
+}
+
+func makeDuels(A, B ifaces.Engine, cfg Config) []*Duel {
+	maker := newDuelMaker(A, B, cfg)
+	duels := []*Duel{}
+	for len(duels) < cfg.Games {
+		duels = append(duels, maker.pair()...)
 	}
 	return duels
 }
@@ -0,0 +1,91 @@
+package comparisons
+
+import (
+	"fmt"
+	"math"
+)
+
+// Stats summarizes a match from the point of view of the first engine
+type Stats struct {
+	Wins, Draws, Losses int
+
+	// Elo difference and the bounds of its 95% confidence interval
+	Elo, EloLow, EloHigh float64
+	// likelihood of superiority, the chance that the
+	// first engine is stronger, between 0 and 1
+	LOS       float64
+	DrawRatio float64
+}
+
+// z score of the 95% confidence interval
+const z95 = 1.959964
+
+func NewStats(wins, draws, losses int) Stats {
+	output := Stats{Wins: wins, Draws: draws, Losses: losses}
+	n := float64(wins + draws + losses)
+	if n == 0 {
+		return output
+	}
+	w, d, l := float64(wins)/n, float64(draws)/n, float64(losses)/n
+	score := w + d/2
+	variance := w*math.Pow(1-score, 2) + d*math.Pow(0.5-score, 2) + l*math.Pow(score, 2)
+	stderr := math.Sqrt(variance / n)
+
+	output.Elo = eloDiff(score)
+	output.EloLow = eloDiff(score - z95*stderr)
+	output.EloHigh = eloDiff(score + z95*stderr)
+	output.DrawRatio = d
+	if wins+losses > 0 {
+		output.LOS = 0.5 * (1 + math.Erf(float64(wins-losses)/math.Sqrt(2*float64(wins+losses))))
+	} else {
+		output.LOS = 0.5
+	}
+	return output
+}
+
+func (this Stats) Games() int {
+	return this.Wins + this.Draws + this.Losses
+}
+
+// Score is the fraction of points of the first engine
+func (this Stats) Score() float64 {
+	n := this.Games()
+	if n == 0 {
+		return 0.5
+	}
+	return (float64(this.Wins) + float64(this.Draws)/2) / float64(n)
+}
+
+// Margin is half the width of the confidence interval
+func (this Stats) Margin() float64 {
+	return (this.EloHigh - this.EloLow) / 2
+}
+
+// eloDiff is the rating difference expected to give the score,
+// infinite if the score is 0 or 1
+func eloDiff(score float64) float64 {
+	if score <= 0 {
+		return math.Inf(-1)
+	}
+	if score >= 1 {
+		return math.Inf(1)
+	}
+	return -400 * math.Log10(1/score-1)
+}
+
+func (this Stats) String() string {
+	return fmt.Sprintf("W/D/L %v/%v/%v, Elo %v [%v, %v], LOS %.1f%%, draws %.1f%%",
+		this.Wins, this.Draws, this.Losses,
+		formatElo(this.Elo), formatElo(this.EloLow), formatElo(this.EloHigh),
+		this.LOS*100, this.DrawRatio*100)
+}
+
+func formatElo(elo float64) string {
+	if math.IsInf(elo, 1) {
+		return "+inf"
+	}
+	if math.IsInf(elo, -1) {
+		return "-inf"
+	}
+	return fmt.Sprintf("%+.1f", elo)
+}
@@ -0,0 +1,102 @@
+package comparisons
+
+import (
+	"chess/game"
+	"chess/game/record"
+
+	"bufio"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Opening is a position the engines play from, once with each colour,
+// given by its FEN and the moves played from it
+type Opening struct {
+	FEN   string   `json:"fen"`
+	Moves []string `json:"moves,omitempty"`
+}
+
+// Play returns the position of the FEN and the position after the moves
+func (this Opening) Play() (*game.GameState, *game.GameState, error) {
+	start, err := game.ParseFEN(this.FEN)
+	if err != nil {
+		return nil, nil, err
+	}
+	rec := &record.Game{Start: this.FEN, Moves: this.Moves}
+	end, err := rec.Replay()
+	if err != nil {
+		return nil, nil, err
+	}
+	if end.IsOver {
+		return nil, nil, errors.New("the game is over after the opening")
+	}
+	return start, end, nil
+}
+
+// ParseOpening reads a FEN, optionally followed by "moves" and
+// the moves, or only the moves, played from the standard position:
+//
+//	rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1 moves e2e3
+//	e2e3 e7e6 d2d3
+func ParseOpening(s string) (Opening, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return Opening{}, errors.New("empty opening")
+	}
+	output := Opening{}
+	if strings.Contains(fields[0], "/") {
+		end := len(fields)
+		for i, f := range fields {
+			if f == "moves" {
+				end = i
+				break
+			}
+		}
+		output.FEN = strings.Join(fields[:end], " ")
+		if end+1 < len(fields) {
+			output.Moves = fields[end+1:]
+		}
+	} else {
+		output.FEN = game.InitialGame(game.InitialBoard()).FEN()
+		output.Moves = fields
+	}
+	_, _, err := output.Play()
+	if err != nil {
+		return Opening{}, err
+	}
+	return output, nil
+}
+
+// LoadOpenings reads a suite of openings, one per line,
+// skipping empty lines and comments starting with #
+func LoadOpenings(path string) ([]Opening, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	output := []Opening{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		op, err := ParseOpening(text)
+		if err != nil {
+			return nil, errors.New(path + ":" + strconv.Itoa(line) + ": " + err.Error())
+		}
+		output = append(output, op)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(output) == 0 {
+		return nil, errors.New(path + " has no openings")
+	}
+	return output, nil
+}
@@ -0,0 +1,255 @@
+package comparisons
+
+import (
+	rs "chess/game/result"
+
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report has the games and statistics of matches,
+// to be read by other programs, as JSON or CSV
+type Report struct {
+	Matches []MatchReport `json:"matches"`
+}
+
+// MatchReport has the statistics from the point of view of First
+type MatchReport struct {
+	First       string       `json:"first"`
+	Second      string       `json:"second"`
+	FirstScore  float64      `json:"first_score"`
+	SecondScore float64      `json:"second_score"`
+	Wins        int          `json:"wins"`
+	Draws       int          `json:"draws"`
+	Losses      int          `json:"losses"`
+	Elo         number       `json:"elo"`
+	EloLow      number       `json:"elo_low"`
+	EloHigh     number       `json:"elo_high"`
+	LOS         float64      `json:"los"`
+	DrawRatio   float64      `json:"draw_ratio"`
+	Games       []GameReport `json:"games"`
+}
+
+type GameReport struct {
+	White string `json:"white"`
+	Black string `json:"black"`
+	// the FEN the game started from, and the moves of the opening
+	Start   string    `json:"start"`
+	Opening []string  `json:"opening,omitempty"`
+	Seed    int64     `json:"seed,omitempty"`
+	Result  rs.Result `json:"result"`
+	Reason  string    `json:"reason,omitempty"`
+	// counting the opening, moves of both sides
+	Plies int `json:"plies"`
+	// summed over the moves of each side
+	WhiteTime  float64 `json:"white_ms"`
+	BlackTime  float64 `json:"black_ms"`
+	WhiteNodes int     `json:"white_nodes"`
+	BlackNodes int     `json:"black_nodes"`
+	// made by the engines
+	Moves []MoveReport `json:"moves"`
+}
+
+type MoveReport struct {
+	Move  string  `json:"move"`
+	Black bool    `json:"black,omitempty"`
+	Time  float64 `json:"ms"`
+	Nodes int     `json:"nodes,omitempty"`
+	Legal int     `json:"legal"`
+	Phase Phase   `json:"phase"`
+}
+
+// number is written as null when it is infinite
+type number float64
+
+func (this number) MarshalJSON() ([]byte, error) {
+	f := float64(this)
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return []byte("null"), nil
+	}
+	return strconv.AppendFloat(nil, f, 'f', -1, 64), nil
+}
+
+func NewReport(matches ...FightResult) *Report {
+	output := &Report{Matches: []MatchReport{}}
+	for _, match := range matches {
+		s := match.Stats
+		mr := MatchReport{
+			First:       match.White.Eng.String(),
+			Second:      match.Black.Eng.String(),
+			FirstScore:  match.White.Score,
+			SecondScore: match.Black.Score,
+			Wins:        s.Wins,
+			Draws:       s.Draws,
+			Losses:      s.Losses,
+			Elo:         number(s.Elo),
+			EloLow:      number(s.EloLow),
+			EloHigh:     number(s.EloHigh),
+			LOS:         s.LOS,
+			DrawRatio:   s.DrawRatio,
+			Games:       []GameReport{},
+		}
+		for i, rec := range match.Games {
+			gr := GameReport{
+				White:  rec.White,
+				Black:  rec.Black,
+				Start:  rec.Start,
+				Result: rec.Result,
+				Reason: rec.Reason,
+				Plies:  len(rec.Moves),
+				Moves:  []MoveReport{},
+			}
+			if i < len(match.Details) {
+				details := match.Details[i]
+				var whiteTime, blackTime time.Duration
+				gr.Seed = details.Seed
+				gr.Opening = rec.Moves[:details.OpeningMoves]
+				for _, mv := range details.Moves {
+					gr.Moves = append(gr.Moves, MoveReport{
+						Move:  mv.Move,
+						Black: mv.Black,
+						Time:  ms(mv.Time),
+						Nodes: mv.Nodes,
+						Legal: mv.Legal,
+						Phase: mv.Phase,
+					})
+					if mv.Black {
+						blackTime += mv.Time
+						gr.BlackNodes += mv.Nodes
+					} else {
+						whiteTime += mv.Time
+						gr.WhiteNodes += mv.Nodes
+					}
+				}
+				gr.WhiteTime, gr.BlackTime = ms(whiteTime), ms(blackTime)
+			}
+			mr.Games = append(mr.Games, gr)
+		}
+		output.Matches = append(output.Matches, mr)
+	}
+	return output
+}
+
+// ms are rounded to the microsecond
+func ms(d time.Duration) float64 {
+	return float64(d.Round(time.Microsecond)/time.Microsecond) / 1000
+}
+
+// CheckReportPath fails if the report can't be saved as path
+func CheckReportPath(path string) error {
+	if !strings.HasSuffix(path, ".json") && !strings.HasSuffix(path, ".csv") {
+		return errors.New("reports are written as .json or .csv: " + path)
+	}
+	return nil
+}
+
+// Save writes the report as JSON if the path ends in .json. If it ends
+// in .csv, the games are written to it and the statistics of the
+// matches next to it, in the same name ending in -matches.csv
+func (this *Report) Save(path string) error {
+	err := CheckReportPath(path)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(path, ".json") {
+		return writeFile(path, this.WriteJSON)
+	}
+	err = writeFile(path, this.WriteGamesCSV)
+	if err != nil {
+		return err
+	}
+	return writeFile(strings.TrimSuffix(path, ".csv")+"-matches.csv", this.WriteMatchesCSV)
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (this *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(this)
+}
+
+// WriteGamesCSV writes a row for each game, with the
+// times of the moves of the engines separated by spaces
+func (this *Report) WriteGamesCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"match", "game", "white", "black", "start", "opening", "seed",
+		"result", "reason", "plies", "white_ms", "black_ms", "white_nodes", "black_nodes", "move_ms"})
+	for i, match := range this.Matches {
+		for j, g := range match.Games {
+			result, _ := g.Result.MarshalText()
+			times := make([]string, len(g.Moves))
+			for k, mv := range g.Moves {
+				times[k] = formatFloat(mv.Time)
+			}
+			out.Write([]string{
+				strconv.Itoa(i + 1),
+				strconv.Itoa(j + 1),
+				g.White,
+				g.Black,
+				g.Start,
+				strings.Join(g.Opening, " "),
+				strconv.FormatInt(g.Seed, 10),
+				string(result),
+				g.Reason,
+				strconv.Itoa(g.Plies),
+				formatFloat(g.WhiteTime),
+				formatFloat(g.BlackTime),
+				strconv.Itoa(g.WhiteNodes),
+				strconv.Itoa(g.BlackNodes),
+				strings.Join(times, " "),
+			})
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteMatchesCSV writes a row with the statistics of each match
+func (this *Report) WriteMatchesCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"match", "first", "second", "games", "first_score", "second_score",
+		"wins", "draws", "losses", "elo", "elo_low", "elo_high", "los", "draw_ratio"})
+	for i, match := range this.Matches {
+		out.Write([]string{
+			strconv.Itoa(i + 1),
+			match.First,
+			match.Second,
+			strconv.Itoa(len(match.Games)),
+			formatFloat(match.FirstScore),
+			formatFloat(match.SecondScore),
+			strconv.Itoa(match.Wins),
+			strconv.Itoa(match.Draws),
+			strconv.Itoa(match.Losses),
+			formatFloat(float64(match.Elo)),
+			formatFloat(float64(match.EloLow)),
+			formatFloat(float64(match.EloHigh)),
+			formatFloat(match.LOS),
+			formatFloat(match.DrawRatio),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,244 @@
+package comparisons
+
+import (
+	"chess/game/record"
+	rs "chess/game/result"
+	ifaces "chess/interfaces"
+
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TournamentFile is the first line of a results file, it has
+// what is needed to play the rest of the tournament
+type TournamentFile struct {
+	Format  string   `json:"format"`
+	Engines []string `json:"engines"`
+	Pairs   [][2]int `json:"pairs,omitempty"`
+	Games   int      `json:"games"`
+	Rounds  int      `json:"rounds,omitempty"`
+	Seed    int64    `json:"seed"`
+	Control string   `json:"control"`
+	// empty if the boards were shuffled
+	Openings     []Opening `json:"openings,omitempty"`
+	Adjudication string    `json:"adjudication,omitempty"`
+}
+
+// Played is a finished game of a tournament
+type Played struct {
+	// round/first engine/second engine/game of the pairing,
+	// the engines by their place in the tournament
+	Key string `json:"key"`
+	// the board was shuffled from it, zero if it wasn't
+	Seed int64 `json:"seed"`
+	// average time of the moves of each side
+	WhiteTime time.Duration `json:"white_time"`
+	BlackTime time.Duration `json:"black_time"`
+	Duration  time.Duration `json:"duration"`
+	Record    *record.Game  `json:"record"`
+	// the first moves of the record are of the opening,
+	// the rest were made by the engines
+	OpeningMoves int        `json:"opening_moves,omitempty"`
+	Moves        []MoveStat `json:"moves,omitempty"`
+	// stack trace of the engine that panicked, if one did
+	Crash string `json:"crash,omitempty"`
+}
+
+// a results file has a line for the tournament
+// followed by a line for each game
+type resultsLine struct {
+	Tournament *TournamentFile `json:"tournament,omitempty"`
+	Game       *Played         `json:"game,omitempty"`
+}
+
+func (this Tournament) file() *TournamentFile {
+	output := &TournamentFile{
+		Format:       this.Format.String(),
+		Engines:      make([]string, len(this.Engines)),
+		Pairs:        this.Pairs,
+		Games:        this.Games,
+		Rounds:       this.Rounds,
+		Seed:         this.Seed,
+		Control:      this.Config.Control.String(),
+		Openings:     this.Config.Openings,
+		Adjudication: this.Config.Adjudication.String(),
+	}
+	for i, eng := range this.Engines {
+		output.Engines[i] = eng.String()
+	}
+	return output
+}
+
+// ReadResults loads a results file. If the process died while
+// writing the last line, the line is ignored
+func ReadResults(path string) (*TournamentFile, []*Played, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var head *TournamentFile
+	played := []*Played{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		l := resultsLine{}
+		err = json.Unmarshal(scanner.Bytes(), &l)
+		if err != nil {
+			last := !bytes.HasSuffix(data, []byte("\n")) &&
+				bytes.HasSuffix(data, scanner.Bytes())
+			if last {
+				break
+			}
+			return nil, nil, errors.New(path + ":" + strconv.Itoa(line) + ": " + err.Error())
+		}
+		switch {
+		case l.Tournament != nil && line == 1:
+			head = l.Tournament
+		case l.Game != nil && head != nil:
+			played = append(played, l.Game)
+		default:
+			return nil, nil, errors.New(path + ":" + strconv.Itoa(line) + ": not a tournament result")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if head == nil {
+		return nil, nil, errors.New(path + ": not a results file")
+	}
+	return head, played, nil
+}
+
+// resultsWriter appends the games to the file as they end
+type resultsWriter struct {
+	f   *os.File
+	err error
+	sync.Mutex
+}
+
+// openResults appends to the results file of the tournament, writing
+// its first line if the file is new, and returns the games already
+// played in it. The file must be of the same tournament
+func openResults(path string, t *Tournament) (*resultsWriter, []*Played, error) {
+	head, played, err := ReadResults(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+	if head != nil {
+		if t.Seed == 0 {
+			t.Seed = head.Seed
+		}
+		if !reflect.DeepEqual(head, t.file()) {
+			return nil, nil, errors.New(path + " has the results of another tournament")
+		}
+	} else if t.Seed == 0 {
+		t.Seed = time.Now().UnixNano()
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := &resultsWriter{f: f}
+	if head == nil {
+		output.write(resultsLine{Tournament: t.file()})
+	} else {
+		output.err = dropCutLine(f)
+	}
+	if output.err != nil {
+		f.Close()
+		return nil, nil, output.err
+	}
+	return output, played, nil
+}
+
+// dropCutLine truncates the last line if it was cut short
+func dropCutLine(f *os.File) error {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return nil
+	}
+	return f.Truncate(int64(bytes.LastIndexByte(data, '\n') + 1))
+}
+
+// write puts the line in a single write, keeping the first error
+func (this *resultsWriter) write(l resultsLine) {
+	data, err := json.Marshal(l)
+	if err != nil {
+		this.err = err
+		return
+	}
+	_, err = this.f.Write(append(data, '\n'))
+	if err != nil && this.err == nil {
+		this.err = err
+	}
+}
+
+func (this *resultsWriter) Save(duel *Duel, res FightResult, taken time.Duration) {
+	this.Mutex.Lock()
+	defer this.Mutex.Unlock()
+	played := &Played{
+		Key:          duel.Key,
+		Seed:         res.Details[0].Seed,
+		WhiteTime:    res.White.Average,
+		BlackTime:    res.Black.Average,
+		Duration:     taken,
+		Record:       res.Games[0],
+		OpeningMoves: res.Details[0].OpeningMoves,
+		Moves:        res.Details[0].Moves,
+	}
+	if len(res.Crashes) > 0 {
+		played.Crash = res.Crashes[0]
+	}
+	this.write(resultsLine{Game: played})
+}
+
+func (this *resultsWriter) Close() error {
+	err := this.f.Close()
+	if this.err != nil {
+		return this.err
+	}
+	return err
+}
+
+// result is the duel as it was played
+func (this *Played) result(white, black ifaces.Engine) FightResult {
+	output := FightResult{
+		White: &EngineScore{Eng: white, Average: this.WhiteTime},
+		Black: &EngineScore{Eng: black, Average: this.BlackTime},
+		Games: []*record.Game{this.Record},
+		Details: []*GameDetails{{
+			Seed:         this.Seed,
+			OpeningMoves: this.OpeningMoves,
+			Moves:        this.Moves,
+		}},
+	}
+	if this.Crash != "" {
+		output.Crashes = []string{this.Crash}
+	}
+	switch this.Record.Result {
+	case rs.Draw:
+		output.White.Score = 0.5
+		output.Black.Score = 0.5
+	case rs.WhiteWins:
+		output.White.Score = 1
+	case rs.BlackWins:
+		output.Black.Score = 1
+	}
+	return output
+}
@@ -0,0 +1,194 @@
+package comparisons
+
+import (
+	ifaces "chess/interfaces"
+
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SPRT is a sequential probability ratio test of H1, that the first
+// engine is Elo1 stronger than the second, against H0, that it is
+// only Elo0 stronger. Games are played in pairs until one is accepted
+type SPRT struct {
+	Elo0, Elo1 float64
+	// chances of accepting H1 when H0 is true, and H0 when H1 is
+	Alpha, Beta float64
+	// stops anyway after this many games, zero means no limit
+	MaxGames int
+}
+
+var DefaultSPRT = SPRT{Elo0: 0, Elo1: 5, Alpha: 0.05, Beta: 0.05}
+
+// ParseSPRT reads the parameters that differ from DefaultSPRT,
+// eg: "elo0=0,elo1=10,alpha=0.05,beta=0.1,games=20000"
+func ParseSPRT(s string) (SPRT, error) {
+	output := DefaultSPRT
+	if strings.TrimSpace(s) == "" {
+		return output, nil
+	}
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return output, errors.New("expected key=value: " + field)
+		}
+		if key == "games" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return output, errors.New("invalid number of games: " + value)
+			}
+			output.MaxGames = n
+			continue
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return output, errors.New("invalid " + key + ": " + value)
+		}
+		switch key {
+		case "elo0":
+			output.Elo0 = f
+		case "elo1":
+			output.Elo1 = f
+		case "alpha":
+			output.Alpha = f
+		case "beta":
+			output.Beta = f
+		default:
+			return output, errors.New("unknown SPRT parameter: " + key)
+		}
+	}
+	if output.Elo1 <= output.Elo0 {
+		return output, errors.New("elo1 must be greater than elo0")
+	}
+	if output.Alpha <= 0 || output.Alpha >= 1 || output.Beta <= 0 || output.Beta >= 1 {
+		return output, errors.New("alpha and beta must be between 0 and 1")
+	}
+	return output, nil
+}
+
+func (this SPRT) String() string {
+	return fmt.Sprintf("elo0=%v,elo1=%v,alpha=%v,beta=%v", this.Elo0, this.Elo1, this.Alpha, this.Beta)
+}
+
+// Bounds are the log likelihood ratios at which H0 and H1 are accepted
+func (this SPRT) Bounds() (lower, upper float64) {
+	return math.Log(this.Beta / (1 - this.Alpha)), math.Log((1 - this.Beta) / this.Alpha)
+}
+
+// LLR is the log likelihood ratio of the results, by the
+// normal approximation of the generalized SPRT
+func (this SPRT) LLR(s Stats) float64 {
+	n := float64(s.Games())
+	if n == 0 {
+		return 0
+	}
+	w, d, l := float64(s.Wins)/n, float64(s.Draws)/n, float64(s.Losses)/n
+	score := s.Score()
+	variance := w*math.Pow(1-score, 2) + d*math.Pow(0.5-score, 2) + l*math.Pow(score, 2)
+	// keeps results that are all the same from dividing by zero
+	if variance < 0.01 {
+		variance = 0.01
+	}
+	s0, s1 := expectedScore(this.Elo0), expectedScore(this.Elo1)
+	return n * (s1 - s0) * (2*score - s0 - s1) / (2 * variance)
+}
+
+// expectedScore is the fraction of points of an engine elo stronger
+func expectedScore(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+type Decision int
+
+const (
+	Inconclusive Decision = iota
+	AcceptH0
+	AcceptH1
+)
+
+func (this Decision) String() string {
+	switch this {
+	case AcceptH0:
+		return "H0 accepted"
+	case AcceptH1:
+		return "H1 accepted"
+	}
+	return "inconclusive"
+}
+
+type SPRTResult struct {
+	FightResult
+	Test     SPRT
+	Decision Decision
+	// when the test was decided, games running at the
+	// time are in the results but not in the LLR
+	LLR          float64
+	Lower, Upper float64
+}
+
+func (this SPRTResult) String() string {
+	return fmt.Sprintf("SPRT %v: %v, LLR %.2f [%.2f, %.2f] after %v games",
+		this.Test, this.Decision, this.LLR, this.Lower, this.Upper, this.Stats.Games())
+}
+
+// RunSPRT plays a against b until the test is decided, cfg.Games is ignored
+func RunSPRT(a, b ifaces.Engine, cfg Config, test SPRT) SPRTResult {
+	maker := newDuelMaker(a, b, cfg)
+	maker.max = test.MaxGames
+	dwl := newDuelWorkList(nil, cfg)
+	dwl.more = maker.pair
+	lower, upper := test.Bounds()
+	output := SPRTResult{Test: test, Lower: lower, Upper: upper}
+
+	dwl.Start(cfg.workers())
+	results := []FightResult{}
+	wins, draws, losses := 0, 0, 0
+	// unknown until the test is decided
+	handedOut := -1
+	for handedOut < 0 || len(results) < handedOut {
+		var res FightResult
+		select {
+		case res = <-dwl.out:
+		case <-cfg.Stop:
+			// stopped with the test undecided
+			if handedOut < 0 {
+				handedOut = dwl.Drain()
+			}
+			cfg.Stop = nil
+			continue
+		}
+		results = append(results, res)
+		if handedOut >= 0 {
+			continue
+		}
+		switch score(res, a) {
+		case 1:
+			wins++
+		case 0.5:
+			draws++
+		default:
+			losses++
+		}
+		stats := NewStats(wins, draws, losses)
+		output.LLR = test.LLR(stats)
+		switch {
+		case output.LLR >= upper:
+			output.Decision = AcceptH1
+		case output.LLR <= lower:
+			output.Decision = AcceptH0
+		case test.MaxGames > 0 && len(results) >= test.MaxGames:
+			output.Decision = Inconclusive
+		default:
+			dwl.SetStatus(fmt.Sprintf("LLR %.2f [%.2f, %.2f] %v",
+				output.LLR, lower, upper, stats))
+			continue
+		}
+		handedOut = dwl.Drain()
+	}
+	dwl.Stop()
+	output.FightResult = tally(a, b, results)
+	return output
+}
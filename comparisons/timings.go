@@ -0,0 +1,265 @@
+package comparisons
+
+import (
+	"chess/game"
+	"chess/game/record"
+
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Phase int
+
+const (
+	InvalidPhase Phase = iota
+	// the first 10 moves
+	PhaseOpening
+	PhaseMiddlegame
+	// 8 pieces or less on the board, kings included and pawns
+	// not, by count alone. The evaluations blend the phases
+	// instead, see common.GamePhase
+	PhaseEndgame
+)
+
+var Phases = []Phase{PhaseOpening, PhaseMiddlegame, PhaseEndgame}
+
+func (this Phase) String() string {
+	switch this {
+	case PhaseOpening:
+		return "opening"
+	case PhaseMiddlegame:
+		return "middlegame"
+	case PhaseEndgame:
+		return "endgame"
+	}
+	return "???"
+}
+
+func (this Phase) MarshalText() ([]byte, error) {
+	return []byte(this.String()), nil
+}
+
+func (this *Phase) UnmarshalText(text []byte) error {
+	for _, p := range Phases {
+		if p.String() == string(text) {
+			*this = p
+			return nil
+		}
+	}
+	*this = InvalidPhase
+	return nil
+}
+
+func phaseOf(g *game.GameState) Phase {
+	switch {
+	case g.TotalValuablePieces <= 8:
+		return PhaseEndgame
+	case g.Moves.Len() < 20:
+		return PhaseOpening
+	}
+	return PhaseMiddlegame
+}
+
+// Distribution sums up the times of a set of moves
+type Distribution struct {
+	Count                            int
+	Min, Median, P90, P99, Max, Mean time.Duration
+}
+
+func NewDistribution(times []time.Duration) Distribution {
+	if len(times) == 0 {
+		return Distribution{}
+	}
+	sorted := make([]time.Duration, len(times))
+	copy(sorted, times)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	// by the nearest rank
+	rank := func(p float64) time.Duration {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+	return Distribution{
+		Count:  len(sorted),
+		Min:    sorted[0],
+		Median: rank(0.5),
+		P90:    rank(0.9),
+		P99:    rank(0.99),
+		Max:    sorted[len(sorted)-1],
+		Mean:   average(sorted),
+	}
+}
+
+// legal moves are counted in buckets of this size, the last is open
+const legalBucket = 10
+const legalBuckets = 6
+
+// Timings are the distributions of the times of the moves of an engine
+type Timings struct {
+	Engine  string
+	All     Distribution
+	ByPhase map[Phase]Distribution
+	// ByLegal[i] are the moves with i*10 to i*10+9 legal moves
+	// to choose from, the last has the moves with more
+	ByLegal [legalBuckets]Distribution
+}
+
+// SlowMove is one of the moves that took the longest
+type SlowMove struct {
+	Engine string
+	// the position the engine moved from
+	FEN   string
+	Move  string
+	Legal int
+	Time  time.Duration
+}
+
+// TimingsOf finds the timings of every engine in the matches
+func TimingsOf(matches ...FightResult) []*Timings {
+	type times struct {
+		all     []time.Duration
+		byPhase map[Phase][]time.Duration
+		byLegal [legalBuckets][]time.Duration
+	}
+	order := []string{}
+	byEngine := map[string]*times{}
+	eachMove(matches, func(engine string, _ *moveAt, mv MoveStat) {
+		t, ok := byEngine[engine]
+		if !ok {
+			t = &times{byPhase: map[Phase][]time.Duration{}}
+			byEngine[engine] = t
+			order = append(order, engine)
+		}
+		t.all = append(t.all, mv.Time)
+		t.byPhase[mv.Phase] = append(t.byPhase[mv.Phase], mv.Time)
+		bucket := mv.Legal / legalBucket
+		if bucket >= legalBuckets {
+			bucket = legalBuckets - 1
+		}
+		t.byLegal[bucket] = append(t.byLegal[bucket], mv.Time)
+	})
+	output := []*Timings{}
+	for _, engine := range order {
+		t := byEngine[engine]
+		timings := &Timings{
+			Engine:  engine,
+			All:     NewDistribution(t.all),
+			ByPhase: map[Phase]Distribution{},
+		}
+		for _, p := range Phases {
+			timings.ByPhase[p] = NewDistribution(t.byPhase[p])
+		}
+		for i := range t.byLegal {
+			timings.ByLegal[i] = NewDistribution(t.byLegal[i])
+		}
+		output = append(output, timings)
+	}
+	return output
+}
+
+// Slowest finds the n moves that took the longest
+func Slowest(n int, matches ...FightResult) []SlowMove {
+	slow := []SlowMove{}
+	where := []*moveAt{}
+	eachMove(matches, func(engine string, at *moveAt, mv MoveStat) {
+		slow = append(slow, SlowMove{Engine: engine, Move: mv.Move, Legal: mv.Legal, Time: mv.Time})
+		where = append(where, at)
+	})
+	order := make([]int, len(slow))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return slow[order[i]].Time > slow[order[j]].Time })
+	if len(order) > n {
+		order = order[:n]
+	}
+	output := make([]SlowMove, len(order))
+	for k, i := range order {
+		output[k] = slow[i]
+		output[k].FEN = where[i].fen()
+	}
+	return output
+}
+
+// moveAt finds the position a move of a game was made from
+type moveAt struct {
+	start string
+	moves []string
+	ply   int
+}
+
+func (this *moveAt) fen() string {
+	rec := &record.Game{Start: this.start, Moves: this.moves[:this.ply]}
+	g, err := rec.Replay()
+	if err != nil {
+		return ""
+	}
+	return g.FEN()
+}
+
+// eachMove calls f with every move made by the engines
+func eachMove(matches []FightResult, f func(engine string, at *moveAt, mv MoveStat)) {
+	for _, match := range matches {
+		for i, rec := range match.Games {
+			if i >= len(match.Details) {
+				continue
+			}
+			details := match.Details[i]
+			for k, mv := range details.Moves {
+				engine := rec.White
+				if mv.Black {
+					engine = rec.Black
+				}
+				at := &moveAt{start: rec.Start, moves: rec.Moves, ply: details.OpeningMoves + k}
+				f(engine, at, mv)
+			}
+		}
+	}
+}
+
+// TimingsTable shows the distributions of the engines,
+// overall, by phase and by the number of legal moves
+func TimingsTable(timings []*Timings) string {
+	sb := &strings.Builder{}
+	row := func(label string, d Distribution) {
+		if d.Count == 0 {
+			return
+		}
+		fmt.Fprintf(sb, "  %-12v %6v %10v %10v %10v %10v %10v\n", label, d.Count,
+			round(d.Min), round(d.Median), round(d.P90), round(d.P99), round(d.Max))
+	}
+	for _, t := range timings {
+		fmt.Fprintf(sb, "%v\n  %-12v %6v %10v %10v %10v %10v %10v\n",
+			t.Engine, "", "moves", "min", "median", "p90", "p99", "max")
+		row("all", t.All)
+		for _, p := range Phases {
+			row(p.String(), t.ByPhase[p])
+		}
+		for i, d := range t.ByLegal {
+			label := fmt.Sprintf("%v-%v legal", i*legalBucket, (i+1)*legalBucket-1)
+			if i == legalBuckets-1 {
+				label = fmt.Sprintf("%v+ legal", i*legalBucket)
+			}
+			row(label, d)
+		}
+	}
+	return sb.String()
+}
+
+// round keeps 3 significant digits or so
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	case d >= time.Microsecond:
+		return d.Round(10 * time.Nanosecond)
+	}
+	return d
+}
@@ -0,0 +1,464 @@
+package comparisons
+
+import (
+	ifaces "chess/interfaces"
+
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Format int
+
+const (
+	InvalidFormat Format = iota
+	// every engine plays every other
+	RoundRobin
+	// the first engine plays every other
+	Gauntlet
+	// each round pairs engines with similar points
+	Swiss
+	// only the pairs given
+	Pairs
+)
+
+func (this Format) String() string {
+	switch this {
+	case RoundRobin:
+		return "roundrobin"
+	case Gauntlet:
+		return "gauntlet"
+	case Swiss:
+		return "swiss"
+	case Pairs:
+		return "pairs"
+	}
+	return "???"
+}
+
+func ParseFormat(s string) (Format, error) {
+	for _, f := range []Format{RoundRobin, Gauntlet, Swiss, Pairs} {
+		if f.String() == s {
+			return f, nil
+		}
+	}
+	return InvalidFormat, errors.New("unknown tournament format: " + s)
+}
+
+type Tournament struct {
+	Format  Format
+	Engines []ifaces.Engine
+	// games of each pairing, must be even
+	Games int
+	// of Swiss tournaments, zero picks enough to tell the engines apart
+	Rounds int
+	// of Pairs tournaments, the engines by their index
+	Pairs [][2]int
+	// of the openings, zero picks one, or the one in the results file
+	Seed int64
+	// if not empty, each game is appended to this file as it ends,
+	// and the games already in it are not played again
+	Results string
+	// Config.Games and Config.Seed are ignored
+	Config Config
+}
+
+// Standing is the tournament of one engine
+type Standing struct {
+	Engine ifaces.Engine
+	Points float64
+	Byes   int
+	Stats  Stats
+	// rated from every game, the average engine is at zero
+	Elo float64
+}
+
+type TournamentResult struct {
+	Tournament
+	// Pairings[i][j] are the games of engine i against j
+	// from the point of view of i, nil if they didn't meet
+	Pairings [][]*Stats
+	Matches  []FightResult
+	// best first
+	Standings []*Standing
+	// games read from the results file
+	Resumed int
+
+	played  map[string]*Played
+	results *resultsWriter
+}
+
+func (this Tournament) check() error {
+	if this.Format == InvalidFormat {
+		return errors.New("invalid tournament format")
+	}
+	if len(this.Engines) < 2 {
+		return errors.New("a tournament needs at least two engines")
+	}
+	if this.Games <= 0 || this.Games%2 != 0 {
+		return errors.New("the number of games of each pairing must be even")
+	}
+	for _, p := range this.Pairs {
+		if p[0] == p[1] || p[0] < 0 || p[1] < 0 ||
+			p[0] >= len(this.Engines) || p[1] >= len(this.Engines) {
+			return errors.New("invalid pair of engines")
+		}
+	}
+	if this.Format == Pairs && len(this.Pairs) == 0 {
+		return errors.New("no pairs of engines")
+	}
+	names := map[string]bool{}
+	for _, eng := range this.Engines {
+		if names[eng.String()] {
+			return errors.New(eng.String() + " is in the tournament twice")
+		}
+		names[eng.String()] = true
+	}
+	return nil
+}
+
+func RunTournament(t Tournament) (*TournamentResult, error) {
+	err := t.check()
+	if err != nil {
+		return nil, err
+	}
+	n := len(t.Engines)
+	output := &TournamentResult{
+		Pairings:  make([][]*Stats, n),
+		Standings: make([]*Standing, n),
+		played:    map[string]*Played{},
+	}
+	if t.Results != "" {
+		var played []*Played
+		output.results, played, err = openResults(t.Results, &t)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range played {
+			output.played[p.Key] = p
+		}
+	} else if t.Seed == 0 {
+		t.Seed = time.Now().UnixNano()
+	}
+	output.Tournament = t
+	for i, eng := range t.Engines {
+		output.Pairings[i] = make([]*Stats, n)
+		output.Standings[i] = &Standing{Engine: eng}
+	}
+	switch t.Format {
+	case RoundRobin:
+		pairs := [][2]int{}
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				pairs = append(pairs, [2]int{i, j})
+			}
+		}
+		output.play(0, pairs)
+	case Gauntlet:
+		pairs := [][2]int{}
+		for j := 1; j < n; j++ {
+			pairs = append(pairs, [2]int{0, j})
+		}
+		output.play(0, pairs)
+	case Pairs:
+		output.play(0, t.Pairs)
+	case Swiss:
+		rounds := t.Rounds
+		if rounds == 0 {
+			rounds = int(math.Ceil(math.Log2(float64(n))))
+		}
+		// a stopped tournament pairs no more rounds, and
+		// the bye of an unfinished round isn't counted
+		for r := 0; r < rounds && !t.Config.stopped(); r++ {
+			pairs, bye := output.swissRound()
+			output.play(r, pairs)
+			if bye >= 0 && !t.Config.stopped() {
+				output.standingsByEngine()[bye].Byes++
+				output.updateStandings()
+			}
+		}
+	}
+	if output.results != nil {
+		err = output.results.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	output.rate()
+	sort.SliceStable(output.Standings, func(i, j int) bool {
+		a, b := output.Standings[i], output.Standings[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		return a.Elo > b.Elo
+	})
+	return output, nil
+}
+
+// play runs the games of the pairs at once, on the same workers,
+// except those already in the results file
+func (this *TournamentResult) play(round int, pairs [][2]int) {
+	cfg := this.Config
+	cfg.Games = this.Games
+	duels := []*Duel{}
+	results := []FightResult{}
+	for _, p := range pairs {
+		prefix := fmt.Sprintf("%v/%v/%v", round, p[0], p[1])
+		cfg.Seed = pairingSeed(this.Seed, prefix)
+		for k, duel := range makeDuels(this.Engines[p[0]], this.Engines[p[1]], cfg) {
+			duel.Key = fmt.Sprintf("%v/%v", prefix, k+1)
+			played, ok := this.played[duel.Key]
+			if ok {
+				results = append(results, played.result(duel.White, duel.Black))
+				this.Resumed++
+				continue
+			}
+			duels = append(duels, duel)
+		}
+	}
+	dwl := newDuelWorkList(duels, cfg)
+	if this.results != nil {
+		dwl.finished = this.results.Save
+	}
+	dwl.Start(cfg.workers())
+	results = append(results, dwl.GetResults()...)
+	dwl.Stop()
+	this.add(pairs, results)
+}
+
+// pairingSeed gives each pairing its own openings, so that they
+// don't depend on the order in which pairings are played
+func pairingSeed(seed int64, pairing string) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%v/%v", seed, pairing)
+	output := int64(h.Sum64() >> 1)
+	if output == 0 {
+		return 1
+	}
+	return output
+}
+
+func (this *TournamentResult) index(eng ifaces.Engine) int {
+	for i, e := range this.Engines {
+		if e == eng {
+			return i
+		}
+	}
+	panic("engine not in the tournament: " + eng.String())
+}
+
+// add tallies the results of each pair as a match
+func (this *TournamentResult) add(pairs [][2]int, results []FightResult) {
+	byPair := map[[2]int][]FightResult{}
+	for _, res := range results {
+		i, j := this.index(res.White.Eng), this.index(res.Black.Eng)
+		if i > j {
+			i, j = j, i
+		}
+		byPair[[2]int{i, j}] = append(byPair[[2]int{i, j}], res)
+	}
+	for _, p := range pairs {
+		i, j := p[0], p[1]
+		key := [2]int{i, j}
+		if i > j {
+			key = [2]int{j, i}
+		}
+		// not played, the tournament was stopped
+		if len(byPair[key]) == 0 {
+			continue
+		}
+		match := tally(this.Engines[i], this.Engines[j], byPair[key])
+		this.Matches = append(this.Matches, match)
+		s := match.Stats
+		this.addStats(i, j, s.Wins, s.Draws, s.Losses)
+		this.addStats(j, i, s.Losses, s.Draws, s.Wins)
+	}
+	this.updateStandings()
+}
+
+func (this *TournamentResult) addStats(i, j int, wins, draws, losses int) {
+	old := this.Pairings[i][j]
+	if old != nil {
+		wins, draws, losses = old.Wins+wins, old.Draws+draws, old.Losses+losses
+	}
+	s := NewStats(wins, draws, losses)
+	this.Pairings[i][j] = &s
+}
+
+// updateStandings sums the games of each engine, a bye is worth
+// as many points as the games it would have played
+func (this *TournamentResult) updateStandings() {
+	for i, st := range this.standingsByEngine() {
+		wins, draws, losses := 0, 0, 0
+		for _, s := range this.Pairings[i] {
+			if s != nil {
+				wins, draws, losses = wins+s.Wins, draws+s.Draws, losses+s.Losses
+			}
+		}
+		st.Stats = NewStats(wins, draws, losses)
+		st.Points = float64(wins) + float64(draws)/2 + float64(st.Byes*this.Games)
+	}
+}
+
+// swissRound pairs the engines in order of points, avoiding
+// rematches when possible. With an odd number of engines,
+// the lowest that hasn't had a bye sits out and gets a point,
+// it is returned as bye, or -1 if every engine plays
+func (this *TournamentResult) swissRound() (pairs [][2]int, bye int) {
+	standings := this.standingsByEngine()
+	order := make([]int, len(this.Engines))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return standings[order[a]].Points > standings[order[b]].Points
+	})
+	bye = -1
+	if len(order)%2 != 0 {
+		k := len(order) - 1
+		for ; k >= 0; k-- {
+			if standings[order[k]].Byes == 0 {
+				break
+			}
+		}
+		if k < 0 {
+			k = len(order) - 1
+		}
+		bye = order[k]
+		order = append(order[:k], order[k+1:]...)
+	}
+	pairs = [][2]int{}
+	paired := make([]bool, len(order))
+	for a := range order {
+		if paired[a] {
+			continue
+		}
+		// the closest in points that we haven't met, or the closest at all
+		partner := -1
+		for b := a + 1; b < len(order); b++ {
+			if paired[b] {
+				continue
+			}
+			if partner < 0 {
+				partner = b
+			}
+			if this.Pairings[order[a]][order[b]] == nil {
+				partner = b
+				break
+			}
+		}
+		paired[a], paired[partner] = true, true
+		pairs = append(pairs, [2]int{order[a], order[partner]})
+	}
+	return pairs, bye
+}
+
+// rate finds the ratings that best explain every game played, by the
+// minorization-maximization algorithm for the Bradley-Terry model, with
+// draws as half a win. Each engine also draws a game against a virtual
+// average opponent, so that no rating is infinite
+func (this *TournamentResult) rate() {
+	n := len(this.Engines)
+	strength := make([]float64, n)
+	for i := range strength {
+		strength[i] = 1
+	}
+	for iter := 0; iter < 1000; iter++ {
+		next := make([]float64, n)
+		for i := 0; i < n; i++ {
+			points := 0.5
+			denominator := 1 / (strength[i] + 1)
+			for j := 0; j < n; j++ {
+				s := this.Pairings[i][j]
+				if s == nil {
+					continue
+				}
+				points += float64(s.Wins) + float64(s.Draws)/2
+				denominator += float64(s.Games()) / (strength[i] + strength[j])
+			}
+			next[i] = points / denominator
+		}
+		strength = next
+	}
+	mean := 0.0
+	for i := range strength {
+		mean += 400 * math.Log10(strength[i])
+	}
+	mean /= float64(n)
+	for i, st := range this.standingsByEngine() {
+		st.Elo = 400*math.Log10(strength[i]) - mean
+	}
+}
+
+// standingsByEngine are in the order of the engines
+func (this *TournamentResult) standingsByEngine() []*Standing {
+	output := make([]*Standing, len(this.Engines))
+	for _, st := range this.Standings {
+		output[this.index(st.Engine)] = st
+	}
+	return output
+}
+
+// Crosstable has a row for each engine, in order of standing, with the
+// points it made against each other engine, or . if they didn't meet
+func (this *TournamentResult) Crosstable() string {
+	width := 0
+	for _, eng := range this.Engines {
+		if len(eng.String()) > width {
+			width = len(eng.String())
+		}
+	}
+	cell := len(fmt.Sprintf("%.1f", float64(this.Games)*float64(len(this.Engines)))) + 1
+	if cell < 5 {
+		cell = 5
+	}
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "%3v %-*v", "", width, "")
+	for k := range this.Standings {
+		fmt.Fprintf(sb, " %*v", cell, k+1)
+	}
+	fmt.Fprintf(sb, " %*v\n", cell+1, "points")
+	for k, st := range this.Standings {
+		i := this.index(st.Engine)
+		fmt.Fprintf(sb, "%3v %-*v", k+1, width, st.Engine)
+		for _, other := range this.Standings {
+			j := this.index(other.Engine)
+			s := this.Pairings[i][j]
+			switch {
+			case i == j:
+				fmt.Fprintf(sb, " %*v", cell, "---")
+			case s == nil:
+				fmt.Fprintf(sb, " %*v", cell, ".")
+			default:
+				fmt.Fprintf(sb, " %*.1f", cell, float64(s.Wins)+float64(s.Draws)/2)
+			}
+		}
+		fmt.Fprintf(sb, " %*.1f\n", cell+1, st.Points)
+	}
+	return sb.String()
+}
+
+// StandingsTable lists the engines from the best, with their
+// combined Elo, the average engine being at zero
+func (this *TournamentResult) StandingsTable() string {
+	width := 0
+	for _, eng := range this.Engines {
+		if len(eng.String()) > width {
+			width = len(eng.String())
+		}
+	}
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "%3v %-*v %7v %6v %13v %6v %7v\n",
+		"", width, "engine", "points", "games", "W/D/L", "score", "elo")
+	for k, st := range this.Standings {
+		wdl := fmt.Sprintf("%v/%v/%v", st.Stats.Wins, st.Stats.Draws, st.Stats.Losses)
+		fmt.Fprintf(sb, "%3v %-*v %7.1f %6v %13v %5.1f%% %7v\n",
+			k+1, width, st.Engine, st.Points, st.Stats.Games(), wdl,
+			st.Stats.Score()*100, formatElo(st.Elo))
+	}
+	return sb.String()
+}
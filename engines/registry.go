@@ -0,0 +1,237 @@
+package engines
+
+import (
+	"chess/game"
+	. "chess/interfaces"
+
+	"chess/evals/custom"
+	"chess/evals/material"
+	"chess/evals/old"
+	"chess/evals/psqt"
+
+	"chess/searches/alphabeta"
+	"chess/searches/minimax"
+	"chess/searches/negamax"
+	"chess/searches/quiescence"
+	"chess/searches/randcapt"
+	"chess/searches/random"
+	"chess/searches/typeB"
+
+	"errors"
+	"strconv"
+	"strings"
+)
+
+type EvalKind struct {
+	Name        string
+	Description string
+	Eval        Evaluator
+}
+
+var Evaluators = []*EvalKind{
+	{"custom", "material, position, mobility, pawn structure and king safety", custom.Evaluate},
+	{"psqt", "material and piece square tables", psqt.Evaluate},
+	{"material", "material only", material.Evaluate},
+	{"old", "the first material evaluation", old.Evaluate},
+	{"none", "every position is equal", func(*game.GameState, int) int { return 0 }},
+}
+
+type Param struct {
+	Name        string
+	Default     string
+	Description string
+}
+
+type SearchKind struct {
+	Name        string
+	Description string
+	Params      []Param
+
+	build func(name string, args args) (Engine, error)
+}
+
+var depthParam = Param{"depth", "2", "plies searched"}
+var evalParam = Param{"eval", "custom", "evaluation function"}
+
+var Searches = []*SearchKind{
+	{
+		Name:        "random",
+		Description: "plays a random move",
+		build:       basic(random.BestMove),
+	},
+	{
+		Name:        "randcapt",
+		Description: "plays a random capture if there's one, a random move otherwise",
+		build:       basic(randcapt.BestMove),
+	},
+	{
+		Name:        "minimax",
+		Description: "plain minimax",
+		Params:      []Param{depthParam, evalParam},
+		build:       basic(minimax.BestMove),
+	},
+	{
+		Name:        "negamax",
+		Description: "plain negamax",
+		Params:      []Param{depthParam, evalParam},
+		build:       basic(negamax.BestMove),
+	},
+	{
+		Name:        "alphabeta",
+		Description: "minimax with alpha-beta pruning",
+		Params:      []Param{depthParam, evalParam},
+		build:       basic(alphabeta.BestMove),
+	},
+	{
+		Name:        "quiescence",
+		Description: "alpha-beta that keeps searching captures until the position is quiet",
+		Params: []Param{
+			depthParam,
+			{"qdepth", "10", "plies searched for captures after depth"},
+			evalParam,
+		},
+		build: func(name string, a args) (Engine, error) {
+			return &IntermediateEngine{
+				Name:     name,
+				Search:   quiescence.BestMove,
+				Eval:     a.eval("eval"),
+				Depth:    a.int("depth"),
+				ExtDepth: a.int("qdepth"),
+			}, a.err
+		},
+	},
+	{
+		Name:        "typeb",
+		Description: "searches only the best moves by a static evaluation",
+		Params: []Param{
+			{"depth", "5", "plies searched"},
+			{"breadth", "5/7/9/9/15/15", "moves searched at each remaining depth"},
+			evalParam,
+		},
+		build: func(name string, a args) (Engine, error) {
+			e := &TypeBEngine{
+				Name:    name,
+				Search:  typeB.BestMove,
+				Eval:    a.eval("eval"),
+				Depth:   a.int("depth"),
+				Breadth: a.ints("breadth"),
+			}
+			if a.err == nil && len(e.Breadth) <= e.Depth {
+				return nil, errors.New("breadth needs more than depth values")
+			}
+			return e, a.err
+		},
+	},
+}
+
+func basic(search BasicSearch) func(string, args) (Engine, error) {
+	return func(name string, a args) (Engine, error) {
+		e := &BasicEngine{
+			Name:   name,
+			Search: search,
+			Eval:   findEval("none").Eval,
+		}
+		// random searches take no parameters
+		if _, ok := a.values["depth"]; ok {
+			e.Eval = a.eval("eval")
+			e.Depth = a.int("depth")
+		}
+		return e, a.err
+	}
+}
+
+func findSearch(name string) *SearchKind {
+	for _, s := range Searches {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func findEval(name string) *EvalKind {
+	for _, e := range Evaluators {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// Build creates the engine described by the spec,
+// missing parameters take their default values
+func Build(spec string) (Engine, error) {
+	s, err := ParseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	kind := findSearch(s.Search)
+	if kind == nil {
+		return nil, errors.New("unknown search: " + s.Search)
+	}
+	a := args{values: map[string]string{}}
+	for _, p := range kind.Params {
+		a.values[p.Name] = p.Default
+	}
+	for key, value := range s.Params {
+		if _, ok := a.values[key]; !ok {
+			return nil, errors.New(s.Search + " has no parameter '" + key + "'")
+		}
+		a.values[key] = value
+	}
+	return kind.build(kind.canonical(a), a)
+}
+
+// canonical writes every parameter in order, so that
+// equal engines always have the same name
+func (this *SearchKind) canonical(a args) string {
+	if len(this.Params) == 0 {
+		return this.Name
+	}
+	values := make([]string, len(this.Params))
+	for i, p := range this.Params {
+		values[i] = p.Name + "=" + a.values[p.Name]
+	}
+	return this.Name + "(" + strings.Join(values, ",") + ")"
+}
+
+// args converts the parameters, keeping the first error
+type args struct {
+	values map[string]string
+	err    error
+}
+
+func (this *args) int(name string) int {
+	n, err := strconv.Atoi(this.values[name])
+	if err != nil || n <= 0 {
+		this.fail(name + " must be a positive integer")
+	}
+	return n
+}
+
+func (this *args) ints(name string) []int {
+	output := []int{}
+	for _, s := range strings.Split(this.values[name], "/") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			this.fail(name + " must be a list of positive integers separated by '/'")
+		}
+		output = append(output, n)
+	}
+	return output
+}
+
+func (this *args) eval(name string) Evaluator {
+	e := findEval(this.values[name])
+	if e == nil {
+		this.fail("unknown evaluation: " + this.values[name])
+		return nil
+	}
+	return e.Eval
+}
+
+func (this *args) fail(message string) {
+	if this.err == nil {
+		this.err = errors.New(message)
+	}
+}
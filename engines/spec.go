@@ -0,0 +1,92 @@
+package engines
+
+import (
+	"errors"
+	"strings"
+)
+
+// Spec describes an engine as a search and its parameters,
+// written like alphabeta(depth=4,eval=psqt)
+type Spec struct {
+	Search string
+	Params map[string]string
+}
+
+// ParseSpec reads the spec without checking the parameters,
+// values may themselves contain balanced parenthesis
+func ParseSpec(s string) (*Spec, error) {
+	s = strings.TrimSpace(s)
+	open := strings.Index(s, "(")
+	if open < 0 {
+		if !isName(s) {
+			return nil, errors.New("invalid engine: " + s)
+		}
+		return &Spec{Search: s, Params: map[string]string{}}, nil
+	}
+	if !strings.HasSuffix(s, ")") {
+		return nil, errors.New("missing ')' in engine: " + s)
+	}
+	spec := &Spec{Search: s[:open], Params: map[string]string{}}
+	if !isName(spec.Search) {
+		return nil, errors.New("invalid engine: " + s)
+	}
+	args, err := splitArgs(s[open+1 : len(s)-1])
+	if err != nil {
+		return nil, errors.New(err.Error() + " in engine: " + s)
+	}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if !ok || !isName(key) || value == "" {
+			return nil, errors.New("invalid parameter '" + arg + "' in engine: " + s)
+		}
+		if _, ok := spec.Params[key]; ok {
+			return nil, errors.New("repeated parameter '" + key + "' in engine: " + s)
+		}
+		spec.Params[key] = value
+	}
+	return spec, nil
+}
+
+// splitArgs splits on the commas outside of parenthesis
+func splitArgs(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	output := []string{}
+	level := 0
+	start := 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			level++
+		case ')':
+			level--
+			if level < 0 {
+				return nil, errors.New("unbalanced ')'")
+			}
+		case ',':
+			if level == 0 {
+				output = append(output, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if level != 0 {
+		return nil, errors.New("unbalanced '('")
+	}
+	return append(output, s[start:]), nil
+}
+
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
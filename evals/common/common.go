@@ -11,43 +11,81 @@ func (this *PieceSquareTable) AtPos(pos game.Point) int {
 	return (*this)[pos.Column+8*pos.Row]
 }
 
-func GetPositionalWeight(isEndgame, isBlack bool, p pc.Piece, pos game.Point) int {
+// Tapered is a value in the middlegame and in the endgame,
+// blended by the phase of the game
+type Tapered struct {
+	MG, EG int
+}
+
+func (this Tapered) Add(other Tapered) Tapered {
+	return Tapered{MG: this.MG + other.MG, EG: this.EG + other.EG}
+}
+
+func (this Tapered) Sub(other Tapered) Tapered {
+	return Tapered{MG: this.MG - other.MG, EG: this.EG - other.EG}
+}
+
+// Taper blends the values, phase goes from
+// MaxPhase in the opening to 0 with bare kings and pawns
+func (this Tapered) Taper(phase int) int {
+	return (this.MG*phase + this.EG*(MaxPhase-phase)) / MaxPhase
+}
+
+// MaxPhase is the phase of the starting material,
+// counted as PeSTO does: minors 1, rooks 2 and queens 4
+const MaxPhase = 24
+
+func PhaseWeight(p pc.Piece) int {
+	switch p {
+	case pc.WhiteQueen, pc.BlackQueen:
+		return 4
+	case pc.WhiteRook, pc.BlackRook:
+		return 2
+	case pc.WhiteBishop, pc.BlackBishop, pc.WhiteKnight, pc.BlackKnight:
+		return 1
+	}
+	return 0
+}
+
+// GamePhase is the phase of the remaining material, promotions
+// can take it past MaxPhase, so it is capped
+func GamePhase(g *game.GameState) int {
+	phase := 0
+	for _, slot := range g.WhitePieces {
+		if !slot.IsInvalid() {
+			phase += PhaseWeight(slot.Piece)
+		}
+	}
+	for _, slot := range g.BlackPieces {
+		if !slot.IsInvalid() {
+			phase += PhaseWeight(slot.Piece)
+		}
+	}
+	if phase > MaxPhase {
+		return MaxPhase
+	}
+	return phase
+}
+
+func GetPositionalScore(isBlack bool, p pc.Piece, pos game.Point) Tapered {
 	if isBlack {
 		pos = Mirror(pos)
 	}
 	switch p {
 	case pc.WhiteQueen, pc.BlackQueen:
-		if isEndgame {
-			return eg_queenTable.AtPos(pos)
-		}
-		return mg_queenTable.AtPos(pos)
+		return Tapered{MG: mg_queenTable.AtPos(pos), EG: eg_queenTable.AtPos(pos)}
 	case pc.WhiteKing, pc.BlackKing:
-		if isEndgame {
-			return eg_kingTable.AtPos(pos)
-		}
-		return mg_kingTable.AtPos(pos)
+		return Tapered{MG: mg_kingTable.AtPos(pos), EG: eg_kingTable.AtPos(pos)}
 	case pc.WhiteRook, pc.BlackRook:
-		if isEndgame {
-			return eg_rookTable.AtPos(pos)
-		}
-		return mg_rookTable.AtPos(pos)
+		return Tapered{MG: mg_rookTable.AtPos(pos), EG: eg_rookTable.AtPos(pos)}
 	case pc.WhiteBishop, pc.BlackBishop:
-		if isEndgame {
-			return eg_bishopTable.AtPos(pos)
-		}
-		return mg_bishopTable.AtPos(pos)
+		return Tapered{MG: mg_bishopTable.AtPos(pos), EG: eg_bishopTable.AtPos(pos)}
 	case pc.WhiteKnight, pc.BlackKnight:
-		if isEndgame {
-			return eg_knightTable.AtPos(pos)
-		}
-		return mg_knightTable.AtPos(pos)
+		return Tapered{MG: mg_knightTable.AtPos(pos), EG: eg_knightTable.AtPos(pos)}
 	case pc.WhitePawn, pc.BlackPawn:
-		if isEndgame {
-			return eg_pawnTable.AtPos(pos)
-		}
-		return mg_pawnTable.AtPos(pos)
+		return Tapered{MG: mg_pawnTable.AtPos(pos), EG: eg_pawnTable.AtPos(pos)}
 	}
-	return 0
+	return Tapered{}
 }
 
 func Mirror(pos game.Point) game.Point {
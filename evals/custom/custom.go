@@ -34,6 +34,7 @@ func Evaluate(g *game.GameState, depth int) int {
 		}
 	}
 	var total int = 0
+	phase := common.GamePhase(g)
 	for _, slot := range g.WhitePieces {
 		if slot.IsInvalid() {
 			continue
@@ -43,7 +44,7 @@ func Evaluate(g *game.GameState, depth int) int {
 			Pos:     slot.Pos,
 			IsBlack: false,
 		}
-		total += getPieceWeight(g, pinfo) + getPositionalWeight(isEndgame(g), pinfo)
+		total += getPieceWeight(g, phase, pinfo) + getPositionalWeight(phase, pinfo)
 	}
 	for _, slot := range g.BlackPieces {
 		if slot.IsInvalid() {
@@ -54,7 +55,7 @@ func Evaluate(g *game.GameState, depth int) int {
 			Pos:     slot.Pos,
 			IsBlack: true,
 		}
-		total -= getPieceWeight(g, pinfo) + getPositionalWeight(isEndgame(g), pinfo)
+		total -= getPieceWeight(g, phase, pinfo) + getPositionalWeight(phase, pinfo)
 	}
 	return total
 }
@@ -68,13 +69,11 @@ type PieceInfo struct {
 
 const kingWeight int = 10000
 
-func getPieceWeight(g *game.GameState, pinfo *PieceInfo) int {
+// the protection of the king fades as the pieces are traded
+func getPieceWeight(g *game.GameState, phase int, pinfo *PieceInfo) int {
 	if pinfo.Piece.IsKingLike() {
-		if isEndgame(g) {
-			pinfo.Weight = kingWeight
-			return pinfo.Weight
-		}
-		pinfo.Weight = kingWeight + protectionWeight(g, pinfo)
+		protection := common.Tapered{MG: protectionWeight(g, pinfo)}
+		pinfo.Weight = kingWeight + protection.Taper(phase)
 		return pinfo.Weight
 	}
 	var pieceWeight int = 0
@@ -95,10 +94,6 @@ func getPieceWeight(g *game.GameState, pinfo *PieceInfo) int {
 	return pinfo.Weight
 }
 
-func isEndgame(g *game.GameState) bool {
-	return g.TotalValuablePieces <= 8
-}
-
 func protectionWeight(g *game.GameState, pinfo *PieceInfo) int {
 	var weight int = 1
 	for _, offset := range game.KingOffsets {
@@ -393,30 +388,17 @@ var bishop_psqt = common.PieceSquareTable{
 	0, 0, 0, 0, 0, 0, 0, 0,
 }
 
-func getPositionalWeight(isEndgame bool, pinfo *PieceInfo) int {
+func getPositionalWeight(phase int, pinfo *PieceInfo) int {
 	pos := pinfo.Pos
 	if pinfo.IsBlack {
 		pos = common.Mirror(pinfo.Pos)
 	}
-	if isEndgame {
-		switch pinfo.Piece {
-		case pc.WhitePawn, pc.BlackPawn:
-			return pawn_ed_psqt.AtPos(pos)
-		case pc.BlackKing, pc.WhiteKing:
-			return king_ed_psqt.AtPos(pos)
-		case pc.BlackKnight, pc.WhiteKnight:
-			return knight_psqt.AtPos(pos)
-		case pc.BlackRook, pc.WhiteRook:
-			return rook_psqt.AtPos(pos)
-		case pc.BlackBishop, pc.WhiteBishop:
-			return bishop_psqt.AtPos(pos)
-		}
-	}
+	var score common.Tapered
 	switch pinfo.Piece {
 	case pc.WhitePawn, pc.BlackPawn:
-		return pawn_md_psqt.AtPos(pos)
+		score = common.Tapered{MG: pawn_md_psqt.AtPos(pos), EG: pawn_ed_psqt.AtPos(pos)}
 	case pc.BlackKing, pc.WhiteKing:
-		return king_md_psqt.AtPos(pos)
+		score = common.Tapered{MG: king_md_psqt.AtPos(pos), EG: king_ed_psqt.AtPos(pos)}
 	case pc.BlackKnight, pc.WhiteKnight:
 		return knight_psqt.AtPos(pos)
 	case pc.BlackRook, pc.WhiteRook:
@@ -424,7 +406,7 @@ func getPositionalWeight(isEndgame bool, pinfo *PieceInfo) int {
 	case pc.BlackBishop, pc.WhiteBishop:
 		return bishop_psqt.AtPos(pos)
 	}
-	return 0
+	return score.Taper(phase)
 }
 
 func inKingRegion(kingPos, otherPos game.Point) bool {
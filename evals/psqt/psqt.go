@@ -22,21 +22,22 @@ func Evaluate(g *game.GameState, depth int) int {
 		}
 	}
 	var total int = 0
+	var positional Tapered
 	for _, slot := range g.WhitePieces {
 		if slot.IsInvalid() {
 			continue
 		}
-		total += getPieceWeight(slot.Piece) +
-			GetPositionalWeight(isEndgame(g), false, slot.Piece, slot.Pos)
+		total += getPieceWeight(slot.Piece)
+		positional = positional.Add(GetPositionalScore(false, slot.Piece, slot.Pos))
 	}
 	for _, slot := range g.BlackPieces {
 		if slot.IsInvalid() {
 			continue
 		}
-		total -= getPieceWeight(slot.Piece) +
-			GetPositionalWeight(isEndgame(g), true, slot.Piece, slot.Pos)
+		total -= getPieceWeight(slot.Piece)
+		positional = positional.Sub(GetPositionalScore(true, slot.Piece, slot.Pos))
 	}
-	return total
+	return total + positional.Taper(GamePhase(g))
 }
 
 func getPieceWeight(p pc.Piece) int {
@@ -56,10 +57,3 @@ func getPieceWeight(p pc.Piece) int {
 	}
 	return 0
 }
-
-func isEndgame(g *game.GameState) bool {
-	if g.TotalValuablePieces <= 8 {
-		return true
-	}
-	return false
-}
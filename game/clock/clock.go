@@ -0,0 +1,187 @@
+// chess clocks and time controls
+package clock
+
+import (
+	rs "chess/game/result"
+
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Control is a time control: each player starts with Base
+// and gains Increment after every move. If Moves is not
+// zero, Base is added again every Moves moves (a session),
+// otherwise the game is sudden death
+type Control struct {
+	Base      time.Duration
+	Increment time.Duration
+	Moves     int
+}
+
+// IsZero means the game is not timed
+func (this Control) IsZero() bool {
+	return this.Base == 0
+}
+
+// ParseControl reads controls written as [moves/]base[+increment],
+// durations are in Go syntax, eg: 5m, 5m+3s, 40/10m, 40/2m+1s
+func ParseControl(s string) (Control, error) {
+	output := Control{}
+	rest := s
+	if i := strings.Index(rest, "/"); i >= 0 {
+		moves, err := strconv.Atoi(rest[:i])
+		if err != nil || moves <= 0 {
+			return output, errors.New("invalid number of moves in time control: " + s)
+		}
+		output.Moves = moves
+		rest = rest[i+1:]
+	}
+	if i := strings.Index(rest, "+"); i >= 0 {
+		inc, err := time.ParseDuration(rest[i+1:])
+		if err != nil || inc < 0 {
+			return output, errors.New("invalid increment in time control: " + s)
+		}
+		output.Increment = inc
+		rest = rest[:i]
+	}
+	base, err := time.ParseDuration(rest)
+	if err != nil || base <= 0 {
+		return output, errors.New("invalid base time in time control: " + s)
+	}
+	output.Base = base
+	return output, nil
+}
+
+func (this Control) String() string {
+	if this.IsZero() {
+		return "untimed"
+	}
+	output := this.Base.String()
+	if this.Moves > 0 {
+		output = fmt.Sprintf("%v/%v", this.Moves, output)
+	}
+	if this.Increment > 0 {
+		output += "+" + this.Increment.String()
+	}
+	return output
+}
+
+// Clock keeps the time of both players, only
+// the clock of the side to move is running
+type Clock struct {
+	Control Control
+
+	remaining [2]time.Duration
+	moves     [2]int
+
+	running bool
+	black   bool
+	started time.Time
+}
+
+func New(c Control) *Clock {
+	return &Clock{
+		Control:   c,
+		remaining: [2]time.Duration{c.Base, c.Base},
+	}
+}
+
+func side(black bool) int {
+	if black {
+		return 1
+	}
+	return 0
+}
+
+// Start runs the clock of the side to move
+func (this *Clock) Start(black bool) {
+	this.running = true
+	this.black = black
+	this.started = time.Now()
+}
+
+// Stop stops the running clock after the player moved, adding the
+// increment and the next session if needed. Returns false if the
+// flag fell, in which case the time is not replenished
+func (this *Clock) Stop() bool {
+	if !this.running {
+		return true
+	}
+	this.running = false
+	s := side(this.black)
+	this.remaining[s] -= time.Since(this.started)
+	if this.remaining[s] <= 0 {
+		this.remaining[s] = 0
+		return false
+	}
+	this.moves[s]++
+	this.remaining[s] += this.Control.Increment
+	if this.Control.Moves > 0 && this.moves[s]%this.Control.Moves == 0 {
+		this.remaining[s] += this.Control.Base
+	}
+	return true
+}
+
+// Pause stops the running clock without counting a move, as
+// when moves are taken back. The time spent is not given back
+func (this *Clock) Pause() {
+	if !this.running {
+		return
+	}
+	this.running = false
+	s := side(this.black)
+	this.remaining[s] -= time.Since(this.started)
+	if this.remaining[s] < 0 {
+		this.remaining[s] = 0
+	}
+}
+
+// Remaining is the time left for the side,
+// counting the time spent on the current move
+func (this *Clock) Remaining(black bool) time.Duration {
+	output := this.remaining[side(black)]
+	if this.running && this.black == black {
+		output -= time.Since(this.started)
+	}
+	if output < 0 {
+		return 0
+	}
+	return output
+}
+
+// MovesToGo is the number of moves until the next
+// session, or zero on sudden death
+func (this *Clock) MovesToGo(black bool) int {
+	if this.Control.Moves == 0 {
+		return 0
+	}
+	return this.Control.Moves - this.moves[side(black)]%this.Control.Moves
+}
+
+// Flagged is true if the running clock has run out
+func (this *Clock) Flagged() bool {
+	return this.running && this.Remaining(this.black) == 0
+}
+
+// Loss is the result and reason for the side whose flag fell
+func Loss(black bool) (rs.Result, string) {
+	if black {
+		return rs.WhiteWins, "Black ran out of time"
+	}
+	return rs.BlackWins, "White ran out of time"
+}
+
+func (this *Clock) String() string {
+	return fmt.Sprintf("white %v, black %v",
+		format(this.Remaining(false)), format(this.Remaining(true)))
+}
+
+func format(d time.Duration) string {
+	d = d.Round(100 * time.Millisecond)
+	m := int(d / time.Minute)
+	s := (d % time.Minute).Seconds()
+	return fmt.Sprintf("%d:%04.1f", m, s)
+}
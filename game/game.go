@@ -24,8 +24,18 @@ func DebugBoard(slots ...*Slot) *Board {
 }
 
 func ShuffledBoard() *Board {
+	return shuffledBoard(rand.Shuffle)
+}
+
+// ShuffledBoardFrom shuffles the pieces with r, the
+// same source gives the same board
+func ShuffledBoardFrom(r *rand.Rand) *Board {
+	return shuffledBoard(r.Shuffle)
+}
+
+func shuffledBoard(shuffle func(n int, swap func(i, j int))) *Board {
 	bag := []pc.Piece{pc.BlackRook, pc.BlackKnight, pc.BlackBishop, pc.BlackQueen, pc.BlackKing, pc.BlackBishop, pc.BlackKnight, pc.BlackRook}
-	rand.Shuffle(len(bag), func(i, j int) {
+	shuffle(len(bag), func(i, j int) {
 		a := bag[i]
 		bag[i] = bag[j]
 		bag[j] = a
@@ -291,6 +301,17 @@ func (this *MoveStack) Top() (Move, bool) {
 	return this.data[this.top-1], false
 }
 
+func (this *MoveStack) Len() int {
+	return this.top
+}
+
+// List returns the moves from the first to the last one played
+func (this *MoveStack) List() []Move {
+	output := make([]Move, this.top)
+	copy(output, this.data[:this.top])
+	return output
+}
+
 func (this *MoveStack) Copy() *MoveStack {
 	a := &MoveStack{
 		top:  this.top,
@@ -436,6 +457,14 @@ var NullMove = &Move{
 	HasCapture: false,
 }
 
+// End finishes the game for reasons outside the board,
+// like a flag falling or an adjudication
+func (this *GameState) End(result rs.Result, reason string) {
+	this.IsOver = true
+	this.Result = result
+	this.Reason = reason
+}
+
 // returns if the move was sucessful
 // passing your turn is represented by from == to
 func (this *GameState) Move(from, to Point) (bool, *Slot) {
@@ -0,0 +1,35 @@
+package game
+
+import (
+	"math/rand"
+)
+
+// zobrist keys are generated from a fixed seed so that
+// hashes can be stored on disk and read back later
+var zobristPieces [64][16]uint64
+var zobristBlackTurn uint64
+
+func init() {
+	r := rand.New(rand.NewSource(20230216))
+	for i := range zobristPieces {
+		for j := range zobristPieces[i] {
+			zobristPieces[i][j] = r.Uint64()
+		}
+	}
+	zobristBlackTurn = r.Uint64()
+}
+
+// Hash computes the Zobrist hash of the position,
+// the move history is not taken into account
+func (this *GameState) Hash() uint64 {
+	var output uint64 = 0
+	for i, piece := range this.Board {
+		if piece.IsOccupied() {
+			output ^= zobristPieces[i][piece]
+		}
+	}
+	if this.BlackTurn {
+		output ^= zobristBlackTurn
+	}
+	return output
+}
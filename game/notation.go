@@ -0,0 +1,211 @@
+package game
+
+import (
+	pc "chess/game/piece"
+
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// FEN writes the position in Forsyth-Edwards notation, castling
+// and en passant fields are always empty since we have neither,
+// and the halfmove clock counts moves since the last capture
+func (this *GameState) FEN() string {
+	output := ""
+	for row := 0; row < 8; row++ {
+		empty := 0
+		for col := 0; col < 8; col++ {
+			piece := this.Board.At(row, col)
+			if !piece.IsOccupied() {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				output += strconv.Itoa(empty)
+				empty = 0
+			}
+			output += piece.String()
+		}
+		if empty > 0 {
+			output += strconv.Itoa(empty)
+		}
+		if row < 7 {
+			output += "/"
+		}
+	}
+	turn := " w"
+	if this.BlackTurn {
+		turn = " b"
+	}
+	fullmove := 1 + this.Moves.Len()/2
+	return output + turn + " - - " +
+		strconv.Itoa(this.MovesSinceLastCapture) + " " +
+		strconv.Itoa(fullmove)
+}
+
+// ParseFEN reads a position written by FEN, only the placement
+// field is mandatory, castling and en passant fields are ignored
+func ParseFEN(fen string) (*GameState, error) {
+	fields := strings.Fields(fen)
+	if len(fields) == 0 {
+		return nil, errors.New("empty FEN")
+	}
+	board, err := parsePlacement(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	err = board.Validate()
+	if err != nil {
+		return nil, err
+	}
+	g := InitialGame(board)
+	if len(fields) > 1 {
+		switch fields[1] {
+		case "w":
+			g.BlackTurn = false
+		case "b":
+			g.BlackTurn = true
+		default:
+			return nil, errors.New("invalid side to move: " + fields[1])
+		}
+	}
+	if len(fields) > 4 {
+		clock, err := strconv.Atoi(fields[4])
+		if err != nil || clock < 0 || clock >= 50 {
+			return nil, errors.New("invalid halfmove clock: " + fields[4])
+		}
+		g.MovesSinceLastCapture = clock
+	}
+	return g, nil
+}
+
+func parsePlacement(s string) (*Board, error) {
+	b := &Board{}
+	rows := strings.Split(s, "/")
+	if len(rows) != 8 {
+		return nil, errors.New("expected 8 ranks in FEN, got " + strconv.Itoa(len(rows)))
+	}
+	for row, text := range rows {
+		col := 0
+		for _, r := range text {
+			if r >= '1' && r <= '8' {
+				for i := 0; i < int(r-'0') && col < 8; i++ {
+					b.Set(row, col, pc.Empty)
+					col++
+				}
+				continue
+			}
+			piece, ok := PieceFromRune(r)
+			if !ok {
+				return nil, errors.New("invalid piece in FEN: " + string(r))
+			}
+			if col >= 8 {
+				break
+			}
+			b.Set(row, col, piece)
+			col++
+		}
+		if col != 8 || len(text) == 0 {
+			return nil, errors.New("invalid rank in FEN: " + text)
+		}
+	}
+	return b, nil
+}
+
+// PieceFromRune reads a piece as written in FEN, uppercase for white
+func PieceFromRune(r rune) (pc.Piece, bool) {
+	switch r {
+	case 'P':
+		return pc.WhitePawn, true
+	case 'N':
+		return pc.WhiteKnight, true
+	case 'B':
+		return pc.WhiteBishop, true
+	case 'R':
+		return pc.WhiteRook, true
+	case 'Q':
+		return pc.WhiteQueen, true
+	case 'K':
+		return pc.WhiteKing, true
+	case 'p':
+		return pc.BlackPawn, true
+	case 'n':
+		return pc.BlackKnight, true
+	case 'b':
+		return pc.BlackBishop, true
+	case 'r':
+		return pc.BlackRook, true
+	case 'q':
+		return pc.BlackQueen, true
+	case 'k':
+		return pc.BlackKing, true
+	}
+	return pc.InvalidPiece, false
+}
+
+// Validate checks that the board can be played from:
+// one king for each side and no pawns on the back ranks
+func (this *Board) Validate() error {
+	whiteKings := 0
+	blackKings := 0
+	for i, piece := range this {
+		switch piece {
+		case pc.WhiteKing:
+			whiteKings++
+		case pc.BlackKing:
+			blackKings++
+		case pc.WhitePawn, pc.BlackPawn:
+			if i < 8 || i >= 56 {
+				return errors.New("pawn on the back rank: " +
+					Point{Row: i / 8, Column: i % 8}.String())
+			}
+		}
+	}
+	if whiteKings != 1 {
+		return errors.New("white must have exactly one king")
+	}
+	if blackKings != 1 {
+		return errors.New("black must have exactly one king")
+	}
+	return nil
+}
+
+// Coord writes the move as the origin and destination squares, eg: e2e3
+func (this *Move) Coord() string {
+	return this.From.String() + this.To.String()
+}
+
+// ParseCoord reads a move written as by Move.Coord,
+// a trailing promotion piece (eg: a7a8q) is accepted and ignored
+func ParseCoord(s string) (from, to Point, ok bool) {
+	if len(s) != 4 && len(s) != 5 {
+		return Point{}, Point{}, false
+	}
+	from, ok = ParsePoint(s[0:2])
+	if !ok {
+		return Point{}, Point{}, false
+	}
+	to, ok = ParsePoint(s[2:4])
+	if !ok {
+		return Point{}, Point{}, false
+	}
+	return from, to, true
+}
+
+// ParsePoint reads a square as written by Point.String, eg: e2
+func ParsePoint(s string) (Point, bool) {
+	if len(s) != 2 {
+		return Point{}, false
+	}
+	col := s[0]
+	row := s[1]
+	if col >= 'a' && col <= 'h' &&
+		row >= '1' && row <= '8' {
+		return Point{
+			Column: int(col - 'a'),
+			Row:    7 - int(row-'1'),
+		}, true
+	}
+	return Point{}, false
+}
@@ -0,0 +1,101 @@
+// records of finished games, stored as one JSON object per line
+package record
+
+import (
+	"chess/game"
+	rs "chess/game/result"
+
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+)
+
+type Game struct {
+	White  string    `json:"white"`
+	Black  string    `json:"black"`
+	Start  string    `json:"start"`
+	Moves  []string  `json:"moves"`
+	Result rs.Result `json:"result"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// New records the moves played from start to end,
+// end must be a continuation of start
+func New(white, black string, start, end *game.GameState) *Game {
+	moves := end.Moves.List()[start.Moves.Len():]
+	output := &Game{
+		White:  white,
+		Black:  black,
+		Start:  start.FEN(),
+		Moves:  make([]string, len(moves)),
+		Result: end.Result,
+		Reason: end.Reason,
+	}
+	for i := range moves {
+		output.Moves[i] = moves[i].Coord()
+	}
+	return output
+}
+
+// Replay plays the recorded moves from the starting position
+func (this *Game) Replay() (*game.GameState, error) {
+	g, err := game.ParseFEN(this.Start)
+	if err != nil {
+		return nil, err
+	}
+	for i, mv := range this.Moves {
+		from, to, ok := game.ParseCoord(mv)
+		if !ok {
+			return nil, errors.New("invalid move " + strconv.Itoa(i) + ": " + mv)
+		}
+		ok, _ = g.Move(from, to)
+		if !ok {
+			return nil, errors.New("illegal move " + strconv.Itoa(i) + ": " + mv)
+		}
+	}
+	return g, nil
+}
+
+// Append writes the games at the end of the file, creating it if needed
+func Append(path string, games ...*Game) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, g := range games {
+		err = enc.Encode(g)
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+func Load(path string) ([]*Game, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	output := []*Game{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		g := &Game{}
+		err = json.Unmarshal(scanner.Bytes(), g)
+		if err != nil {
+			return nil, errors.New(path + ":" + strconv.Itoa(line) + ": " + err.Error())
+		}
+		output = append(output, g)
+	}
+	return output, scanner.Err()
+}
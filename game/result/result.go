@@ -20,3 +20,30 @@ const (
 	WhiteWins
 	BlackWins
 )
+
+// MarshalText writes the result as in PGN: 1-0, 0-1, 1/2-1/2 or *
+func (this Result) MarshalText() ([]byte, error) {
+	switch this {
+	case Draw:
+		return []byte("1/2-1/2"), nil
+	case WhiteWins:
+		return []byte("1-0"), nil
+	case BlackWins:
+		return []byte("0-1"), nil
+	}
+	return []byte("*"), nil
+}
+
+func (this *Result) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "1/2-1/2":
+		*this = Draw
+	case "1-0":
+		*this = WhiteWins
+	case "0-1":
+		*this = BlackWins
+	default:
+		*this = InvalidResult
+	}
+	return nil
+}
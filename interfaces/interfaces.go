@@ -2,13 +2,107 @@ package interfaces
 
 import (
 	"chess/game"
+
+	"fmt"
+	"time"
 )
 
 type Engine interface {
 	Play(g *game.GameState)
+	// Analyse searches the position without playing the move
+	Analyse(g *game.GameState, lim Limits) Analysis
 	String() string
 }
 
+// DepthLimited is an engine that can't search deeper than MaxDepth
+type DepthLimited interface {
+	MaxDepth() int
+}
+
+// MaxDepth is the deepest the engine can search, zero if it has no limit
+func MaxDepth(eng Engine) int {
+	if dl, ok := eng.(DepthLimited); ok {
+		return dl.MaxDepth()
+	}
+	return 0
+}
+
+// CheckDepth fails if the engine can't search to depth
+func CheckDepth(eng Engine, depth int) error {
+	max := MaxDepth(eng)
+	if max > 0 && depth > max {
+		return fmt.Errorf("%v searches at most %v plies", eng, max)
+	}
+	return nil
+}
+
+// Analysis is the outcome of a search, scores are positive
+// when the position favours white
+type Analysis struct {
+	Move  game.Move
+	Score int
+	PV    []game.Move
+
+	// the score is an evaluation of the position, engines
+	// that pick moves without one leave it false
+	Scored bool
+
+	// the search was stopped before it found a move
+	Stopped bool
+
+	// depth of the last completed search, evaluations
+	// made and time taken, zero if the engine doesn't tell
+	Depth int
+	Nodes int
+	Time  time.Duration
+}
+
+// Limits constrains a single search
+type Limits struct {
+	// closing Stop aborts the search, with Stopped set. A search
+	// that deepens iteratively finishes depth 1 regardless, so
+	// that it has a move, and returns the deepest it completed
+	Stop <-chan struct{}
+
+	// time left on the clock of the side to move, zero if
+	// the game is not timed, and what is gained each move
+	Time      time.Duration
+	Increment time.Duration
+	// moves until the time is replenished, zero on sudden death
+	MovesToGo int
+
+	// fixed time for this move, overrides the clock
+	MoveTime time.Duration
+
+	// overrides the depth of the engine if not zero
+	Depth int
+
+	// if not nil, the search deepens iteratively and
+	// Info is called after each completed iteration
+	Info func(Analysis)
+}
+
+// Budget is how long the search should take, zero if there's no limit.
+// We assume the game lasts 30 more moves, and keep half of the clock
+// for emergencies
+func (this Limits) Budget() time.Duration {
+	if this.MoveTime > 0 {
+		return this.MoveTime
+	}
+	if this.Time <= 0 {
+		return 0
+	}
+	moves := this.MovesToGo
+	if moves == 0 || moves > 30 {
+		moves = 30
+	}
+	output := this.Time/time.Duration(moves) + this.Increment*3/4
+	if output > this.Time/2 {
+		output = this.Time / 2
+	}
+	return output
+}
+
 // BasicEngine does evaluation only on leaf nodes
 // and does not use any form of precomputation
 type BasicEngine struct {
@@ -19,11 +113,13 @@ type BasicEngine struct {
 }
 
 func (this *BasicEngine) Play(g *game.GameState) {
-	bestMove := this.Search(g, this.Eval, this.Depth)
-	ok, _ := g.Move(bestMove.From, bestMove.To)
-	if !ok {
-		panic("engine made ilegal move")
-	}
+	play(g, this.Analyse(g, Limits{}))
+}
+
+func (this *BasicEngine) Analyse(g *game.GameState, lim Limits) Analysis {
+	return run(g, lim, this.Depth, func(g *game.GameState, eval Evaluator, depth int) Analysis {
+		return this.Search(g, eval, depth)
+	}, this.Eval)
 }
 
 func (this *BasicEngine) String() string {
@@ -42,11 +138,13 @@ type IntermediateEngine struct {
 }
 
 func (this *IntermediateEngine) Play(g *game.GameState) {
-	bestMove := this.Search(g, this.Eval, this.ExtDepth, this.Depth)
-	ok, _ := g.Move(bestMove.From, bestMove.To)
-	if !ok {
-		panic("engine made ilegal move")
-	}
+	play(g, this.Analyse(g, Limits{}))
+}
+
+func (this *IntermediateEngine) Analyse(g *game.GameState, lim Limits) Analysis {
+	return run(g, lim, this.Depth, func(g *game.GameState, eval Evaluator, depth int) Analysis {
+		return this.Search(g, eval, this.ExtDepth, depth)
+	}, this.Eval)
 }
 
 func (this *IntermediateEngine) String() string {
@@ -65,18 +163,135 @@ type TypeBEngine struct {
 }
 
 func (this *TypeBEngine) Play(g *game.GameState) {
-	bestMove := this.Search(g, this.Eval, this.Depth, this.Breadth)
-	ok, _ := g.Move(bestMove.From, bestMove.To)
-	if !ok {
-		panic("engine made ilegal move")
+	play(g, this.Analyse(g, Limits{}))
+}
+
+// Analyse searches at most MaxDepth plies, deeper
+// ones have no breadth to search with
+func (this *TypeBEngine) Analyse(g *game.GameState, lim Limits) Analysis {
+	if lim.Depth > this.MaxDepth() {
+		lim.Depth = this.MaxDepth()
 	}
+	return run(g, lim, this.Depth, func(g *game.GameState, eval Evaluator, depth int) Analysis {
+		return this.Search(g, eval, depth, this.Breadth)
+	}, this.Eval)
 }
 
 func (this *TypeBEngine) String() string {
 	return this.Name
 }
 
-type BasicSearch func(g *game.GameState, eval Evaluator, depth int) game.Move
-type ExtendedSearch func(g *game.GameState, eval Evaluator, extdepth, depth int) game.Move
-type TypeBSearch func(g *game.GameState, eval Evaluator, depth int, breadth []int) game.Move
+func (this *TypeBEngine) MaxDepth() int {
+	return len(this.Breadth) - 1
+}
+
+func play(g *game.GameState, an Analysis) {
+	ok, _ := g.Move(an.Move.From, an.Move.To)
+	if !ok {
+		panic("engine made ilegal move")
+	}
+}
+
+type stopped struct{}
+
+type search func(g *game.GameState, eval Evaluator, depth int) Analysis
+
+// run searches a copy of the position, so that an aborted search
+// does not leave the game in an inconsistent state. Searches with
+// a time budget or that report progress deepen iteratively, and
+// return the deepest search that completed
+func run(g *game.GameState, lim Limits, depth int, s search, eval Evaluator) Analysis {
+	if lim.Depth > 0 {
+		depth = lim.Depth
+	}
+	start := time.Now()
+	budget := lim.Budget()
+	if budget == 0 && lim.Info == nil {
+		nodes, counted := count(eval)
+		var an Analysis
+		if lim.Stop == nil {
+			an = s(g.Copy(), counted, depth)
+		} else {
+			an = guarded(g, depth, s, guard(counted, lim.Stop, nil))
+		}
+		an.Depth = depth
+		an.Nodes = *nodes
+		an.Time = time.Since(start)
+		return an
+	}
+	var timeout chan struct{}
+	if budget > 0 {
+		timeout = make(chan struct{})
+		timer := time.AfterFunc(budget, func() { close(timeout) })
+		defer timer.Stop()
+	}
+
+	nodes, counted := count(eval)
+	// depth 1 is always searched to the end, so that we have a move
+	best := s(g.Copy(), counted, 1)
+	report := func(an *Analysis, d int) {
+		an.Depth = d
+		an.Nodes = *nodes
+		an.Time = time.Since(start)
+		if lim.Info != nil {
+			lim.Info(*an)
+		}
+	}
+	report(&best, 1)
+	guardedEval := guard(counted, lim.Stop, timeout)
+	for d := 2; d <= depth; d++ {
+		// the next iteration takes a lot longer than the last,
+		// don't bother starting it if we're past half the budget
+		if budget > 0 && time.Since(start) > budget/2 {
+			break
+		}
+		an := guarded(g, d, s, guardedEval)
+		if an.Stopped {
+			break
+		}
+		report(&an, d)
+		best = an
+	}
+	return best
+}
+
+// count wraps the evaluator so that it counts the positions evaluated
+func count(eval Evaluator) (*int, Evaluator) {
+	nodes := 0
+	return &nodes, func(g *game.GameState, depth int) int {
+		nodes++
+		return eval(g, depth)
+	}
+}
+
+func guarded(g *game.GameState, depth int, s search, eval Evaluator) (out Analysis) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(stopped); !ok {
+				panic(r)
+			}
+			out = Analysis{Move: *game.NullMove, Stopped: true}
+		}
+	}()
+	return s(g.Copy(), eval, depth)
+}
+
+// every search calls the evaluator often enough that it is a good
+// place to check if we should stop, nil channels are never ready
+func guard(eval Evaluator, stop, timeout <-chan struct{}) Evaluator {
+	return func(g *game.GameState, depth int) int {
+		select {
+		case <-stop:
+			panic(stopped{})
+		case <-timeout:
+			panic(stopped{})
+		default:
+		}
+		return eval(g, depth)
+	}
+}
+
+type BasicSearch func(g *game.GameState, eval Evaluator, depth int) Analysis
+type ExtendedSearch func(g *game.GameState, eval Evaluator, extdepth, depth int) Analysis
+type TypeBSearch func(g *game.GameState, eval Evaluator, depth int, breadth []int) Analysis
 type Evaluator func(g *game.GameState, depth int) int
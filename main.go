@@ -2,70 +2,380 @@ package main
 
 import (
 	colors "chess/asciicolors"
+	"chess/book"
+	"chess/bot"
+	"chess/bot/fakeserver"
 	xcmd "chess/command"
 	ck "chess/command/commandkind"
 	comps "chess/comparisons"
 	game "chess/game"
+	"chess/game/clock"
+	"chess/game/record"
 	ifaces "chess/interfaces"
+	"chess/protocol"
+	"chess/server"
+	"chess/store"
+	"chess/stream"
+	"chess/tablebase"
 
 	"chess/engines"
 
 	movegenTest "chess/movegen"
 	seggen "chess/movegen/segregated"
 
+	pc "chess/game/piece"
 	rs "chess/game/result"
 
 	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime/pprof"
 	"sort"
+	"strings"
 	"time"
 )
 
 var asBlack = flag.Bool("black", false, "play as black")
+var ponderFlag = flag.Bool("ponder", true, "let the engine think on your time")
+var recordFile = flag.String("record", "", "append selfplay and compare games to this file")
+var bookFile = flag.String("book", "", "opening book for the engine")
+var tbDir = flag.String("tb", "", "directory with endgame tablebases for the engine")
+var timeControl = flag.String("tc", "", "time control for games and comparisons (eg: 5m+3s, 40/10m)")
+var engineFlag = flag.String("engine", defaultEngine, "name or spec of the engine you play against")
+var protocolFlag = flag.Bool("protocol", false, "talk an UCI like protocol on stdin and stdout instead of the REPL")
+var httpAddr = flag.String("http", "", "serve the JSON API on this address (eg: localhost:8080) instead of the REPL")
+var streamAddr = flag.String("stream", "", "serve the browser UI on this address, where running games can be watched")
+var botURL = flag.String("bot", "", "play as a bot on this lichess-like server (eg: https://lichess.org)")
+var botToken = flag.String("token", "", "API token for -bot, defaults to $LICHESS_TOKEN")
+var savedFile = flag.String("saved", store.DefaultPath(), "file where saved positions are kept")
+var scriptFile = flag.String("script", "", "run the commands of this file and exit, - reads them from stdin")
+var resultsFile = flag.String("results", "", "tournaments append their games to this file, and resume from it")
+var openingsFile = flag.String("openings", "", "file of openings for compare, championship and tournaments, one FEN or list of moves per line")
+var seedFlag = flag.Int64("seed", 0, "seed of the shuffled openings of compare, championship and tournaments, 0 is random")
+var adjudicateFlag = flag.String("adjudicate", "", "adjudication of compare, championship and tournament games (eg: resign=800,resignmoves=4,maxmoves=200)")
+var workersFlag = flag.Int("workers", 0, "games played at once by compare, championship and tournaments, 0 is one for each CPU")
+var reportFile = flag.String("report", "", "write the games and statistics of compare, championship and tournaments to this .json or .csv file")
+var timingsFlag = flag.Int("timings", 0, "after compare, championship and tournaments, show the distribution of the time of the moves and this many of the slowest")
+var keepGoing = flag.Bool("keepgoing", false, "in scripts, run the remaining commands after one fails")
+var genTB = flag.String("gentb", "", "generate the tablebases (eg: KQvK,KPvK) into the -tb directory and exit")
 
 func main() {
 	flag.Parse()
+	input, name := openInput()
+	if *genTB != "" {
+		generateTablebases(*genTB, *tbDir)
+		return
+	}
+	if *httpAddr != "" {
+		fmt.Println("serving on", *httpAddr)
+		fatal(http.ListenAndServe(*httpAddr, server.New(*engineFlag)))
+	}
+	if *protocolFlag {
+		server, err := protocol.NewServer(*engineFlag, *bookFile, *tbDir, os.Stdout)
+		if err != nil {
+			fatal(err)
+		}
+		err = server.Run(os.Stdin)
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+	eng, err := engines.Load(*engineFlag, *bookFile, *tbDir)
+	if err != nil {
+		fatal(err)
+	}
+	opponent = eng
+	if *botURL != "" {
+		runBot(eng)
+		return
+	}
+	// only the REPL has colours to strip, the
+	// other modes write to stdout as it is
+	if scripted {
+		plainOutput()
+	}
+	if *streamAddr != "" {
+		srv := server.New(*engineFlag)
+		events = srv.Events
+		go func() {
+			fatal(http.ListenAndServe(*streamAddr, srv))
+		}()
+		fmt.Printf("watch games on http://%v/watch.html\n", *streamAddr)
+	}
+	if *timeControl != "" {
+		c, err := clock.ParseControl(*timeControl)
+		if err != nil {
+			fatal(err)
+		}
+		control = c
+	}
+	if *openingsFile != "" {
+		ops, err := comps.LoadOpenings(*openingsFile)
+		if err != nil {
+			fatal(err)
+		}
+		openings = ops
+	}
+	adj, err := comps.ParseAdjudication(*adjudicateFlag)
+	if err != nil {
+		fatal(err)
+	}
+	adjudication = adj
+	if *reportFile != "" {
+		err = comps.CheckReportPath(*reportFile)
+		if err != nil {
+			fatal(err)
+		}
+	}
 	cli := newCliState()
 	if !cli.ComputerIsBlack {
 		enginePlay(cli)
 	}
-	for {
-		fmt.Print(">")
-		reader := bufio.NewReader(os.Stdin)
+	cli.startClock()
+	reader := bufio.NewReader(input)
+	for line := 1; ; line++ {
+		if !scripted {
+			fmt.Print(">")
+		}
 		cmdstr, err := reader.ReadString('\n')
-		if err != nil {
-			fatal(err)
+		if err != nil && cmdstr == "" {
+			exit(status)
 		}
 		cmd, err2 := xcmd.Parse(cmdstr)
+		if scripted && (cmd != nil || err2 != nil) {
+			fmt.Println(">", strings.TrimSpace(cmdstr))
+		}
 		if err2 != nil {
+			if scripted {
+				warn(fmt.Sprintf("%v:%v:", name, line), err2)
+				fail(exitInvalid)
+				continue
+			}
 			warn(err2)
 			continue
 		}
+		if cmd == nil {
+			continue
+		}
+		failed = false
 		eval(cli, cmd)
+		if scripted && failed {
+			fail(exitFailed)
+		}
 	}
 }
 
+// exit codes of scripts
+const (
+	exitOK       = 0
+	exitFailed   = 1 // a command failed
+	exitInvalid  = 2 // a command couldn't be parsed
+	exitNoScript = 3 // the script couldn't be opened
+)
+
+// scripted is true if the commands don't come from a terminal,
+// status is the exit code of the script so far
+var scripted bool
+var status = exitOK
+
+// failed is set by warn, so that scripts know a command failed
+var failed bool
+
+// openInput returns where the commands come from and its name
+func openInput() (io.Reader, string) {
+	switch *scriptFile {
+	case "":
+		info, err := os.Stdin.Stat()
+		scripted = err == nil && info.Mode()&os.ModeCharDevice == 0
+		return os.Stdin, "stdin"
+	case "-":
+		scripted = true
+		return os.Stdin, "stdin"
+	}
+	scripted = true
+	f, err := os.Open(*scriptFile)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitNoScript)
+	}
+	return f, *scriptFile
+}
+
+// fail stops the script, unless -keepgoing is set
+func fail(code int) {
+	if code > status {
+		status = code
+	}
+	if !*keepGoing {
+		exit(status)
+	}
+}
+
+// flushOutput waits for what was written to stdout to be printed
+var flushOutput = func() {}
+
+// plainOutput strips the colours of everything written to stdout
+func plainOutput() {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&colors.Stripper{W: stdout}, r)
+		close(done)
+	}()
+	flushOutput = func() {
+		os.Stdout = stdout
+		w.Close()
+		<-done
+	}
+}
+
+func exit(code int) {
+	flushOutput()
+	os.Exit(code)
+}
+
 type cliState struct {
-	Saved map[string]game.GameState
+	Saved *store.Store
 	Curr  *game.GameState
 
 	ComputerIsBlack bool
+
+	Pondering *ponder
+
+	// nil if the game is not timed
+	Clock *clock.Clock
+
+	// moves taken back, the last one is redone first.
+	// Any other move clears it
+	Redo []game.Move
+
+	// override the depth of the engines and limit the time they
+	// take for each move, zero leaves them as they are
+	Depth    int
+	MoveTime time.Duration
+
+	// the position being edited, nil outside of setup mode
+	Setup *setup
+}
+
+type setup struct {
+	Board     game.Board
+	BlackTurn bool
+	// moves since the last capture
+	Counter int
+}
+
+// events publishes the running games if -stream is set, it is nil otherwise
+var events *stream.Broker
+
+// control is the time control given by -tc
+var control clock.Control
+
+// openings are read from -openings
+var openings []comps.Opening
+
+// adjudication is given by -adjudicate
+var adjudication comps.Adjudication
+
+// matchConfig plays each opening twice, or 200 shuffled boards
+func matchConfig() comps.Config {
+	cfg := comps.Config{
+		Games:    200,
+		Control:  control,
+		Stream:   events,
+		Quiet:    scripted,
+		Openings: openings,
+		Seed:     *seedFlag,
+
+		Adjudication: adjudication,
+		Workers:      *workersFlag,
+	}
+	if len(openings) > 0 {
+		cfg.Games = 2 * len(openings)
+	}
+	return cfg
 }
 
 func newCliState() *cliState {
 	return &cliState{
-		Saved:           map[string]game.GameState{},
+		Saved:           openStore(),
 		Curr:            game.InitialGame(game.InitialBoard()),
 		ComputerIsBlack: !*asBlack,
+		Clock:           newClock(),
+	}
+}
+
+// openStore loads the saved positions, if the file is
+// unreadable they are kept only in memory
+func openStore() *store.Store {
+	s, err := store.Open(*savedFile)
+	if err != nil {
+		warn(err)
+		warn("saved positions won't be written to disk")
+		s, _ = store.Open("")
+	}
+	return s
+}
+
+func listSaved(cli *cliState) {
+	for _, label := range cli.Saved.Labels() {
+		e, _ := cli.Saved.Entry(label)
+		position := ""
+		g, err := cli.Saved.Get(label)
+		if err != nil {
+			position = err.Error()
+		} else {
+			position = g.FEN()
+		}
+		fmt.Printf("%-16v %3v moves  %v  %v\n",
+			label, len(e.Moves), e.Saved.Format("2006-01-02 15:04"), position)
 	}
 }
 
+func newClock() *clock.Clock {
+	if control.IsZero() {
+		return nil
+	}
+	return clock.New(control)
+}
+
+// startClock runs the clock of the side to move
+func (cli *cliState) startClock() {
+	if cli.Clock != nil && !cli.Curr.IsOver {
+		cli.Clock.Start(cli.Curr.BlackTurn)
+	}
+}
+
+// stopClock stops the clock after a move was made,
+// if the flag fell the game is lost
+func (cli *cliState) stopClock(black bool) bool {
+	if cli.Clock == nil {
+		return true
+	}
+	if !cli.Clock.Stop() {
+		cli.Curr.End(clock.Loss(black))
+		return false
+	}
+	return true
+}
+
 func warn(stuff ...any) {
+	failed = true
 	fmt.Print("\u001b[31m")
 	fmt.Println(stuff...)
 	fmt.Print("\u001b[0m")
@@ -73,46 +383,108 @@ func warn(stuff ...any) {
 
 func fatal(anything ...any) {
 	fmt.Println(anything...)
-	os.Exit(0)
+	exit(exitFailed)
 }
 
 func eval(cli *cliState, cmd *xcmd.Command) {
+	if cli.Setup != nil {
+		evalSetup(cli, cmd)
+		return
+	}
 	switch cmd.Kind {
+	case ck.Setup:
+		cli.stopPonder()
+		if cli.Clock != nil {
+			cli.Clock.Pause()
+		}
+		cli.Setup = &setup{
+			Board:     cli.Curr.Board,
+			BlackTurn: cli.Curr.BlackTurn,
+			Counter:   cli.Curr.MovesSinceLastCapture,
+		}
+		showSetup(cli)
+	case ck.Put, ck.Remove, ck.Turn, ck.Counter, ck.Done, ck.Cancel:
+		warn(cmd.Kind.String() + " only works in setup mode")
 	case ck.Clear:
 		c := exec.Command("clear")
 		c.Stdout = os.Stdout
 		c.Run()
 	case ck.Quit:
-		os.Exit(0)
+		cli.stopPonder()
+		exit(status)
 	case ck.NO:
 		fmt.Println("i'm sorry :(")
-		os.Exit(0)
+		exit(status)
 	case ck.Save:
 		txt := *cmd.Operands[0].Label
-		cli.Saved[txt] = *cli.Curr
+		err := cli.Saved.Save(txt, cli.Curr)
+		if err != nil {
+			warn(err)
+		}
 	case ck.Restore:
+		cli.stopPonder()
+		// saved games don't keep their clocks, the time starts over
+		cli.Clock = newClock()
+		cli.Redo = nil
 		if len(cmd.Operands) == 0 {
 			cli.Curr = game.InitialGame(game.InitialBoard())
+			cli.startClock()
 			return
 		}
 		txt := *cmd.Operands[0].Label
-		saved, ok := cli.Saved[txt]
-		if !ok {
-			warn(txt + " doesn't exist")
+		saved, err := cli.Saved.Get(txt)
+		if err != nil {
+			warn(err)
+			return
+		}
+		cli.Curr = saved
+		cli.startClock()
+	case ck.List:
+		listSaved(cli)
+	case ck.Delete:
+		err := cli.Saved.Delete(*cmd.Operands[0].Label)
+		if err != nil {
+			warn(err)
+		}
+	case ck.Export:
+		labels := []string{}
+		for _, op := range cmd.Operands[1:] {
+			labels = append(labels, *op.Label)
+		}
+		err := cli.Saved.Export(*cmd.Operands[0].Label, labels...)
+		if err != nil {
+			warn(err)
+		}
+	case ck.Import:
+		labels, err := cli.Saved.Import(*cmd.Operands[0].Label)
+		if err != nil {
+			warn(err)
 			return
 		}
-		cli.Curr = &saved
+		fmt.Println("imported:", strings.Join(labels, " "))
 	case ck.Move:
+		if cli.Clock != nil && cli.Clock.Flagged() {
+			cli.stopClock(cli.Curr.BlackTurn)
+		}
 		if isOver(cli) {
+			cli.stopPonder()
 			return
 		}
+		black := cli.Curr.BlackTurn
 		if evalMove(cli, cmd) {
+			cli.Redo = nil
+			cli.stopClock(black)
 			if isOver(cli) {
+				cli.stopPonder()
 				return
 			}
 			start := time.Now()
 			enginePlay(cli)
 			fmt.Printf("%v\n", time.Since(start))
+			if cli.Clock != nil {
+				fmt.Println(cli.Clock)
+			}
+			cli.startClock()
 		}
 		if isOver(cli) {
 			return
@@ -122,24 +494,132 @@ func eval(cli *cliState, cmd *xcmd.Command) {
 		f, err := os.Create(file)
 		if err != nil {
 			warn(err)
-			os.Exit(0)
+			return
 		}
 		pprof.StartCPUProfile(f)
+	case ck.Undo:
+		undo(cli, plies(cmd))
+	case ck.Redo:
+		redo(cli, plies(cmd))
+	case ck.Takeback:
+		takeback(cli)
+	case ck.Engine:
+		evalEngine(cli, cmd)
+	case ck.Engines:
+		listEngines()
+	case ck.Depth:
+		depth := int(*cmd.Operands[0].Number)
+		err := ifaces.CheckDepth(opponent, depth)
+		if err != nil {
+			warn(err)
+			return
+		}
+		cli.stopPonder()
+		cli.Depth = depth
+		showSettings(cli)
+	case ck.MoveTime:
+		cli.stopPonder()
+		cli.MoveTime = time.Duration(*cmd.Operands[0].Number) * time.Second
+		if len(cmd.Operands) == 2 {
+			unit := xcmd.TimeUnits[*cmd.Operands[1].Label]
+			cli.MoveTime = time.Duration(*cmd.Operands[0].Number) * unit
+		}
+		showSettings(cli)
 	case ck.SelfPlay:
-		doSelfPlay(cli)
+		cli.stopPonder()
+		cli.Redo = nil
+		doSelfPlay(cli, cmd)
 	case ck.Compare:
+		cli.stopPonder()
 		evalCompare(cli, cmd)
 	case ck.Championship:
+		cli.stopPonder()
 		evalChampionship()
+	case ck.Tournament:
+		cli.stopPonder()
+		evalTournament(cmd)
 	case ck.StopProfile:
 		pprof.StopCPUProfile()
 	case ck.Test:
+		cli.stopPonder()
 		test()
+	case ck.Book:
+		evalBook(cmd)
 	case ck.Show:
 		evalShow(cli, cmd)
 	}
 }
 
+// evalSetup edits the position, the board is
+// only checked once the setup is done
+func evalSetup(cli *cliState, cmd *xcmd.Command) {
+	s := cli.Setup
+	switch cmd.Kind {
+	case ck.Clear:
+		for i := range s.Board {
+			s.Board[i] = pc.Empty
+		}
+	case ck.Put:
+		for _, op := range cmd.Operands {
+			piece, pos, _ := xcmd.ParsePlacement(*op.Label)
+			s.Board.SetPos(pos, piece)
+		}
+	case ck.Remove:
+		for _, op := range cmd.Operands {
+			s.Board.SetPos(*op.Position, pc.Empty)
+		}
+	case ck.Turn:
+		s.BlackTurn = *cmd.Operands[0].Label == "black"
+	case ck.Counter:
+		s.Counter = 0
+		if len(cmd.Operands) == 1 {
+			s.Counter = int(*cmd.Operands[0].Number)
+		}
+	case ck.Show, ck.Setup:
+	case ck.Done:
+		err := s.Board.Validate()
+		if err != nil {
+			warn(err)
+			return
+		}
+		g := game.InitialGame(&s.Board)
+		g.BlackTurn = s.BlackTurn
+		g.MovesSinceLastCapture = s.Counter
+		cli.Setup = nil
+		cli.Curr = g
+		cli.Redo = nil
+		cli.Clock = newClock()
+		fmt.Println(g.FEN())
+		if g.BlackTurn == cli.ComputerIsBlack {
+			enginePlay(cli)
+			isOver(cli)
+		}
+		cli.startClock()
+		return
+	case ck.Cancel:
+		cli.Setup = nil
+		cli.startClock()
+		return
+	case ck.Quit, ck.NO:
+		cli.Setup = nil
+		eval(cli, cmd)
+	default:
+		warn("finish the setup with done or cancel first")
+		return
+	}
+	showSetup(cli)
+}
+
+func showSetup(cli *cliState) {
+	s := cli.Setup
+	fmt.Println(s.Board.String())
+	side := "white"
+	if s.BlackTurn {
+		side = "black"
+	}
+	fmt.Printf("%v to move, %v moves since the last capture\n", side, s.Counter)
+}
+
 func evalMove(cli *cliState, cmd *xcmd.Command) bool {
 	from := *cmd.Operands[0].Position
 	to := *cmd.Operands[1].Position
@@ -151,6 +631,81 @@ func evalMove(cli *cliState, cmd *xcmd.Command) bool {
 	return true
 }
 
+// plies is the optional operand of undo and redo
+func plies(cmd *xcmd.Command) int {
+	if len(cmd.Operands) == 0 {
+		return 1
+	}
+	return int(*cmd.Operands[0].Number)
+}
+
+// undo takes back up to n plies, keeping them to be redone.
+// Returns the number of plies taken back
+func undo(cli *cliState, n int) int {
+	cli.stopPonder()
+	if cli.Clock != nil {
+		cli.Clock.Pause()
+	}
+	undone := []string{}
+	for i := 0; i < n && cli.Curr.Moves.Len() > 0; i++ {
+		moves := cli.Curr.Moves.List()
+		mv := moves[len(moves)-1]
+		cli.Curr.UnMove()
+		cli.Redo = append(cli.Redo, mv)
+		undone = append(undone, mv.Coord())
+	}
+	if len(undone) == 0 {
+		warn("no moves to undo")
+	} else {
+		fmt.Println("undone:", strings.Join(undone, " "))
+	}
+	cli.startClock()
+	return len(undone)
+}
+
+func redo(cli *cliState, n int) {
+	cli.stopPonder()
+	if cli.Clock != nil {
+		cli.Clock.Pause()
+	}
+	redone := []string{}
+	for i := 0; i < n && len(cli.Redo) > 0; i++ {
+		mv := cli.Redo[len(cli.Redo)-1]
+		cli.Redo = cli.Redo[:len(cli.Redo)-1]
+		ok, _ := cli.Curr.Move(mv.From, mv.To)
+		if !ok {
+			// can't happen unless the position changed under the stack
+			cli.Redo = nil
+			warn("can't redo " + mv.Coord())
+			break
+		}
+		redone = append(redone, mv.Coord())
+	}
+	if len(redone) == 0 {
+		warn("no moves to redo")
+	} else {
+		fmt.Println("redone:", strings.Join(redone, " "))
+	}
+	isOver(cli)
+	cli.startClock()
+}
+
+// takeback undoes the reply of the engine and the last move
+// of the player, so that it's the player's turn again
+func takeback(cli *cliState) {
+	playerIsBlack := !cli.ComputerIsBlack
+	n := 2
+	if cli.Curr.BlackTurn != playerIsBlack {
+		// the game ended before the engine replied
+		n = 1
+	}
+	if cli.Curr.Moves.Len() < n {
+		warn("no moves to take back")
+		return
+	}
+	undo(cli, n)
+}
+
 func evalShow(cli *cliState, cmd *xcmd.Command) {
 	if len(cmd.Operands) == 0 {
 		fmt.Println(cli.Curr.Board.String())
@@ -179,8 +734,113 @@ func evalShow(cli *cliState, cmd *xcmd.Command) {
 	}
 }
 
+const defaultEngine = "typeb_psqt"
+
+var opponent ifaces.Engine
+
+// limits are the settings of the REPL for a search
+func (cli *cliState) limits() ifaces.Limits {
+	return ifaces.Limits{
+		Depth:    cli.Depth,
+		MoveTime: cli.MoveTime,
+	}
+}
+
 func enginePlay(cli *cliState) {
-	engines.TypeB_Psqt.Play(cli.Curr)
+	black := cli.Curr.BlackTurn
+	lim := cli.limits()
+	if cli.Clock != nil {
+		lim.Time = cli.Clock.Remaining(black)
+		lim.Increment = control.Increment
+		lim.MovesToGo = cli.Clock.MovesToGo(black)
+		cli.Clock.Start(black)
+	}
+	an, hit := cli.ponderHit(lim)
+	if hit {
+		fmt.Println("ponder hit")
+	} else {
+		an = opponent.Analyse(cli.Curr, lim)
+	}
+	if !cli.stopClock(black) {
+		return
+	}
+	if an.Move == *game.NullMove {
+		warn(opponent.String() + " made no move")
+		return
+	}
+	ok, _ := cli.Curr.Move(an.Move.From, an.Move.To)
+	if !ok {
+		warn(opponent.String() + " made an illegal move: " + an.Move.Coord())
+		return
+	}
+	if *ponderFlag && len(an.PV) > 1 {
+		cli.startPonder(an.PV[1])
+	}
+}
+
+// ponder is a search running on the position we expect
+// after the reply predicted by the engine
+type ponder struct {
+	Expected *game.GameState
+	Stop     chan struct{}
+	Result   chan ifaces.Analysis
+}
+
+func (cli *cliState) startPonder(reply game.Move) {
+	expected := cli.Curr.Copy()
+	ok, _ := expected.Move(reply.From, reply.To)
+	if !ok || expected.IsOver {
+		return
+	}
+	p := &ponder{
+		Expected: expected,
+		Stop:     make(chan struct{}),
+		Result:   make(chan ifaces.Analysis, 1),
+	}
+	// deepening iteratively, so that stopping it on a
+	// hit still gives the deepest search that completed
+	lim := ifaces.Limits{Stop: p.Stop, Depth: cli.Depth, Info: func(ifaces.Analysis) {}}
+	go func() {
+		p.Result <- opponent.Analyse(p.Expected, lim)
+	}()
+	cli.Pondering = p
+}
+
+// ponderHit returns the pondered analysis if the player made the
+// expected reply, the search goes on for at most the budget of lim.
+// Otherwise the search is discarded
+func (cli *cliState) ponderHit(lim ifaces.Limits) (ifaces.Analysis, bool) {
+	p := cli.Pondering
+	if p == nil {
+		return ifaces.Analysis{}, false
+	}
+	if !samePosition(p.Expected, cli.Curr) {
+		cli.stopPonder()
+		return ifaces.Analysis{}, false
+	}
+	cli.Pondering = nil
+	if budget := lim.Budget(); budget > 0 {
+		timer := time.AfterFunc(budget, func() { close(p.Stop) })
+		defer timer.Stop()
+	}
+	an := <-p.Result
+	return an, !an.Stopped
+}
+
+// stopPonder waits for the search to stop, searches
+// share their node counters and must not overlap
+func (cli *cliState) stopPonder() {
+	if cli.Pondering != nil {
+		close(cli.Pondering.Stop)
+		<-cli.Pondering.Result
+		cli.Pondering = nil
+	}
+}
+
+func samePosition(a, b *game.GameState) bool {
+	return a.Board == b.Board &&
+		a.BlackTurn == b.BlackTurn &&
+		a.MovesSinceLastCapture == b.MovesSinceLastCapture
 }
 
 func isOver(cli *cliState) bool {
@@ -198,123 +858,466 @@ func isOver(cli *cliState) bool {
 	return false
 }
 
-func doSelfPlay(cli *cliState) {
+// selfplays counts the selfplay games, to name them on the stream
+var selfplays int
+
+// doSelfPlay plays the current position to the end, by default
+// with the opponent on both sides, or the given engines for white and black
+func doSelfPlay(cli *cliState, cmd *xcmd.Command) {
+	white, black := opponent, opponent
+	for i, op := range cmd.Operands {
+		eng, err := engines.Load(*op.Label, *bookFile, *tbDir)
+		if err != nil {
+			warn(err)
+			return
+		}
+		if i == 0 {
+			white, black = eng, eng
+		} else {
+			black = eng
+		}
+	}
+	selfplays++
+	publish := func(e stream.Event) {
+		e.Game = fmt.Sprintf("selfplay.%v", selfplays)
+		e.White, e.Black = white.String(), black.String()
+		e.FEN = cli.Curr.FEN()
+		events.Publish(e)
+	}
+	publish(stream.Event{Kind: stream.Start})
+	start := cli.Curr.Copy()
 	for !isOver(cli) {
+		side, eng := "WHITE", white
 		if cli.Curr.BlackTurn {
-			fmt.Println("BLACK -------------")
-			start := time.Now()
-			engines.QuiescenceIII.Play(cli.Curr)
-			fmt.Printf("BLACK: %v\n", time.Since(start))
-		} else {
-			fmt.Println("WHITE --------------")
-			start := time.Now()
-			engines.QuiescenceIII.Play(cli.Curr)
-			fmt.Printf("WHITE: %v\n", time.Since(start))
+			side, eng = "BLACK", black
+		}
+		fmt.Printf("%v -------------\n", side)
+		t := time.Now()
+		an := eng.Analyse(cli.Curr, cli.limits())
+		if an.Move == *game.NullMove {
+			warn(eng.String() + " made no move")
+			return
+		}
+		ok, _ := cli.Curr.Move(an.Move.From, an.Move.To)
+		if !ok {
+			warn(eng.String() + " made an illegal move: " + an.Move.Coord())
+			return
 		}
+		fmt.Printf("%v: %v\n", side, time.Since(t))
+		publish(stream.Event{
+			Kind:  stream.Move,
+			Ply:   cli.Curr.Moves.Len(),
+			Move:  an.Move.Coord(),
+			Score: an.Score,
+		})
 		fmt.Println(cli.Curr.Board.String())
 		fmt.Println("--------------------------")
 	}
+	publish(stream.Event{Kind: stream.End, Result: cli.Curr.Result, Reason: cli.Curr.Reason})
+	saveRecords(record.New(white.String(), black.String(), start, cli.Curr))
 }
 
-type engineScore struct {
-	eng     ifaces.Engine
-	score   float64
-	times   []time.Duration
-	average time.Duration
+// evalEngine changes the opponent, or shows it if no engine is given
+func evalEngine(cli *cliState, cmd *xcmd.Command) {
+	if len(cmd.Operands) == 1 {
+		eng, err := engines.Load(*cmd.Operands[0].Label, *bookFile, *tbDir)
+		if err != nil {
+			warn(err)
+			return
+		}
+		cli.stopPonder()
+		opponent = eng
+	}
+	showSettings(cli)
 }
 
-func makeBoards(number int) []*game.Board {
-	boards := make([]*game.Board, number)
-	for i := 0; i < number; i++ {
-		boards[i] = game.ShuffledBoard()
+func showSettings(cli *cliState) {
+	depth := "engine default"
+	if cli.Depth > 0 {
+		depth = fmt.Sprint(cli.Depth)
+	}
+	movetime := "unlimited"
+	if cli.MoveTime > 0 {
+		movetime = cli.MoveTime.String()
 	}
-	return boards
+	fmt.Printf("engine: %v, depth: %v, movetime: %v\n", opponent, depth, movetime)
+}
+
+func listEngines() {
+	fmt.Println("named engines:")
+	for _, n := range engines.Named {
+		fmt.Printf("  %-20v %v\n", n.Name, n.Spec)
+	}
+	fmt.Println("searches:")
+	for _, s := range engines.Searches {
+		fmt.Printf("  %-20v %v\n", s.Name, s.Description)
+		for _, p := range s.Params {
+			fmt.Printf("      %-16v %v (default %v)\n", p.Name, p.Description, p.Default)
+		}
+	}
+	fmt.Println("evaluations:")
+	for _, e := range engines.Evaluators {
+		fmt.Printf("  %-20v %v\n", e.Name, e.Description)
+	}
+}
+
+func saveRecords(games ...*record.Game) {
+	if *recordFile == "" {
+		return
+	}
+	err := record.Append(*recordFile, games...)
+	if err != nil {
+		warn(err)
+	}
+}
+
+// showTimings prints the distributions of the times of the moves
+// of each engine, and the slowest moves, if asked by -timings
+func showTimings(matches ...comps.FightResult) {
+	if *timingsFlag <= 0 {
+		return
+	}
+	fmt.Print(comps.TimingsTable(comps.TimingsOf(matches...)))
+	fmt.Println("slowest moves:")
+	for _, mv := range comps.Slowest(*timingsFlag, matches...) {
+		fmt.Printf("  %10v %-20v %-6v %3v legal  %v\n",
+			mv.Time.Round(time.Microsecond), mv.Engine, mv.Move, mv.Legal, mv.FEN)
+	}
+}
+
+// saveReport writes the matches to -report, replacing what it had
+func saveReport(matches ...comps.FightResult) {
+	if *reportFile == "" {
+		return
+	}
+	err := comps.NewReport(matches...).Save(*reportFile)
+	if err != nil {
+		warn(err)
+	}
+}
+
+func evalBook(cmd *xcmd.Command) {
+	games, err := record.Load(*cmd.Operands[0].Label)
+	if err != nil {
+		warn(err)
+		return
+	}
+	if len(cmd.Operands) == 3 {
+		games = filterLayout(games, *cmd.Operands[2].Label)
+	}
+	b, err := book.Build(games, book.DefaultPlies)
+	if err != nil {
+		warn(err)
+		return
+	}
+	err = b.Save(*cmd.Operands[1].Label)
+	if err != nil {
+		warn(err)
+		return
+	}
+	fmt.Printf("%v positions from %v games\n", len(b.Positions), len(games))
+}
+
+func filterLayout(games []*record.Game, layout string) []*record.Game {
+	standard := game.InitialGame(game.InitialBoard()).FEN()
+	output := []*record.Game{}
+	for _, g := range games {
+		if (g.Start == standard) == (layout == "standard") {
+			output = append(output, g)
+		}
+	}
+	return output
 }
 
 func evalCompare(cli *cliState, cmd *xcmd.Command) {
 	eng0Name := *cmd.Operands[0].Label
 	eng1Name := *cmd.Operands[1].Label
 
-	eng0, ok := engines.AllEngines[eng0Name]
-	if !ok {
-		warn("engine not found", eng0Name)
+	eng0, err := engines.Get(eng0Name)
+	if err != nil {
+		warn(err)
 		return
 	}
-	eng1, ok := engines.AllEngines[eng1Name]
-	if !ok {
-		warn("engine not found: ", eng1Name)
+	eng1, err := engines.Get(eng1Name)
+	if err != nil {
+		warn(err)
 		return
 	}
 	start := time.Now()
-	res := comps.Compare(eng0, eng1, 200)
+	cfg := matchConfig()
+	stop, done := interruptible()
+	defer done()
+	cfg.Stop = stop
+	if len(cmd.Operands) > 2 {
+		params := ""
+		if len(cmd.Operands) == 4 {
+			params = *cmd.Operands[3].Label
+		}
+		test, err := comps.ParseSPRT(params)
+		if err != nil {
+			warn(err)
+			return
+		}
+		res := comps.RunSPRT(eng0, eng1, cfg, test)
+		saveRecords(res.Games...)
+		saveReport(res.FightResult)
+		reportCrashes(res.Crashes)
+		fmt.Println("final: ", res.FightResult)
+		fmt.Println(res.Stats)
+		fmt.Println(res)
+		showTimings(res.FightResult)
+		fmt.Println("comparison took: ", time.Since(start))
+		return
+	}
+	res := comps.Run(eng0, eng1, cfg)
+	saveRecords(res.Games...)
+	saveReport(res)
+	reportCrashes(res.Crashes)
 	fmt.Println("final: ", res)
+	fmt.Println(res.Stats)
+	showTimings(res)
 	fmt.Println("comparison took: ", time.Since(start))
 }
 
 type duel struct {
-	A ifaces.Engine
-	B ifaces.Engine
+	A string
+	B string
 }
 
 var duels = []duel{
-	//{engines.TypeB_Mat, engines.Random},
-	//{engines.TypeB_Mat, engines.RandCapt},
-	//{engines.TypeB_Mat, engines.AlphaBetaII_Mat},
-	//{engines.TypeB, engines.AlphaBetaII},
-	//{engines.TypeB_Psqt, engines.AlphaBetaII_Psqt},
-
-	//{engines.Random, engines.RandCapt},
-	//{engines.Minimax, engines.Random},
-	//{engines.Minimax, engines.Minimax_Mat},
-	//{engines.Minimax, engines.Minimax_Psqt},
-	//{engines.Minimax, engines.RandCapt},
-	//{engines.Minimax, engines.MinimaxII},
-
-	{engines.AlphaBeta_Mat, engines.RandCapt},
-	{engines.AlphaBeta_Psqt, engines.RandCapt},
-	{engines.AlphaBeta, engines.RandCapt},
-
-	{engines.AlphaBeta_Mat, engines.AlphaBetaII_Mat},
-	{engines.AlphaBeta_Mat, engines.AlphaBetaIII_Mat},
-	{engines.AlphaBetaII_Mat, engines.AlphaBetaIII_Mat},
-	{engines.AlphaBetaIII_Mat, engines.AlphaBetaIV_Mat},
-	{engines.AlphaBetaIV_Mat, engines.AlphaBetaV_Mat},
-
-	{engines.AlphaBeta_Psqt, engines.AlphaBetaII_Psqt},
-	{engines.AlphaBeta_Psqt, engines.AlphaBetaIII_Psqt},
-	{engines.AlphaBetaII_Psqt, engines.AlphaBetaIII_Psqt},
-	{engines.AlphaBetaIII_Psqt, engines.AlphaBetaIV_Psqt},
-	{engines.AlphaBetaIV_Psqt, engines.AlphaBetaV_Psqt},
-
-	//{engines.Quiescence, engines.AlphaBeta},
-	//{engines.Quiescence_Mat, engines.AlphaBeta_Mat},
-	//{engines.Quiescence_Psqt, engines.AlphaBeta_Psqt},
-
-	//{engines.QuiescenceII, engines.AlphaBetaII},
-	//{engines.QuiescenceII_Mat, engines.AlphaBetaII_Mat},
-	//{engines.QuiescenceII_Psqt, engines.AlphaBetaII_Psqt},
-
-	//{engines.QuiescenceIII, engines.AlphaBetaIII},
-	//{engines.QuiescenceIII_Mat, engines.AlphaBetaIII_Mat},
-	//{engines.QuiescenceIII_Psqt, engines.AlphaBetaIII_Psqt},
+	//{"typeb_mat", "random"},
+	//{"typeb_mat", "randcapt"},
+	//{"typeb_mat", "alphabetaII_mat"},
+	//{"typeb", "alphabetaII"},
+	//{"typeb_psqt", "alphabetaII_psqt"},
+
+	//{"random", "randcapt"},
+	//{"minimax", "random"},
+	//{"minimax", "minimax_mat"},
+	//{"minimax", "minimax_psqt"},
+	//{"minimax", "randcapt"},
+	//{"minimax", "minimaxII"},
+
+	{"alphabeta_mat", "randcapt"},
+	{"alphabeta_psqt", "randcapt"},
+	{"alphabeta", "randcapt"},
+
+	{"alphabeta_mat", "alphabetaII_mat"},
+	{"alphabeta_mat", "alphabetaIII_mat"},
+	{"alphabetaII_mat", "alphabetaIII_mat"},
+	{"alphabetaIII_mat", "alphabetaIV_mat"},
+	{"alphabetaIV_mat", "alphabetaV_mat"},
+
+	{"alphabeta_psqt", "alphabetaII_psqt"},
+	{"alphabeta_psqt", "alphabetaIII_psqt"},
+	{"alphabetaII_psqt", "alphabetaIII_psqt"},
+	{"alphabetaIII_psqt", "alphabetaIV_psqt"},
+	{"alphabetaIV_psqt", "alphabetaV_psqt"},
+
+	//{"quiescence", "alphabeta"},
+	//{"quiescence_mat", "alphabeta_mat"},
+	//{"quiescence_psqt", "alphabeta_psqt"},
+
+	//{"quiescenceII", "alphabetaII"},
+	//{"quiescenceII_mat", "alphabetaII_mat"},
+	//{"quiescenceII_psqt", "alphabetaII_psqt"},
+
+	//{"quiescenceIII", "alphabetaIII"},
+	//{"quiescenceIII_mat", "alphabetaIII_mat"},
+	//{"quiescenceIII_psqt", "alphabetaIII_psqt"},
+}
+
+func evalTournament(cmd *xcmd.Command) {
+	if *cmd.Operands[0].Label == "resume" {
+		path := *resultsFile
+		if len(cmd.Operands) > 1 {
+			path = *cmd.Operands[1].Label
+		}
+		t, err := resumeTournament(path)
+		if err != nil {
+			warn(err)
+			return
+		}
+		runTournament(t)
+		return
+	}
+	format, err := comps.ParseFormat(*cmd.Operands[0].Label)
+	if err != nil {
+		warn(err)
+		return
+	}
+	t := comps.Tournament{
+		Format:  format,
+		Games:   int(*cmd.Operands[1].Number),
+		Seed:    *seedFlag,
+		Results: *resultsFile,
+		Config:  matchConfig(),
+	}
+	for _, op := range cmd.Operands[2:] {
+		eng, err := engines.Get(*op.Label)
+		if err != nil {
+			warn(err)
+			return
+		}
+		t.Engines = append(t.Engines, eng)
+	}
+	runTournament(t)
+}
+
+// resumeTournament reads the tournament of a results file,
+// to play the games missing from it
+func resumeTournament(path string) (comps.Tournament, error) {
+	t := comps.Tournament{Results: path}
+	if path == "" {
+		return t, errors.New("no results file to resume, give one or use -results")
+	}
+	head, _, err := comps.ReadResults(path)
+	if err != nil {
+		return t, err
+	}
+	t.Format, err = comps.ParseFormat(head.Format)
+	if err != nil {
+		return t, err
+	}
+	for _, name := range head.Engines {
+		eng, err := engines.Get(name)
+		if err != nil {
+			return t, err
+		}
+		t.Engines = append(t.Engines, eng)
+	}
+	c := clock.Control{}
+	if head.Control != c.String() {
+		c, err = clock.ParseControl(head.Control)
+		if err != nil {
+			return t, err
+		}
+	}
+	t.Games = head.Games
+	t.Rounds = head.Rounds
+	t.Pairs = head.Pairs
+	t.Seed = head.Seed
+	adj, err := comps.ParseAdjudication(head.Adjudication)
+	if err != nil {
+		return t, err
+	}
+	t.Config = matchConfig()
+	t.Config.Control = c
+	t.Config.Openings = head.Openings
+	t.Config.Adjudication = adj
+	return t, nil
+}
+
+func runTournament(t comps.Tournament) {
+	start := time.Now()
+	stop, done := interruptible()
+	defer done()
+	t.Config.Stop = stop
+	res, err := comps.RunTournament(t)
+	if err != nil {
+		warn(err)
+		return
+	}
+	if res.Resumed > 0 {
+		fmt.Printf("%v games were read from %v\n", res.Resumed, t.Results)
+	}
+	saveReport(res.Matches...)
+	for _, match := range res.Matches {
+		saveRecords(match.Games...)
+		reportCrashes(match.Crashes)
+		fmt.Println(match)
+		fmt.Println("    ", match.Stats)
+	}
+	fmt.Println()
+	fmt.Print(res.Crosstable())
+	fmt.Println()
+	fmt.Print(res.StandingsTable())
+	showTimings(res.Matches...)
+	fmt.Println("tournament took: ", time.Since(start))
 }
 
 func evalChampionship() {
-	allFights := []comps.FightResult{}
+	t := comps.Tournament{
+		Format:  comps.Pairs,
+		Seed:    *seedFlag,
+		Results: *resultsFile,
+		Config:  matchConfig(),
+	}
+	t.Games = t.Config.Games
+	index := map[string]int{}
 	for _, duel := range duels {
-		start := time.Now()
-		res := comps.Compare(duel.A, duel.B, 200)
-		allFights = append(allFights, res)
-		fmt.Println(res, " : ", time.Since(start))
+		pair := [2]int{}
+		for k, name := range []string{duel.A, duel.B} {
+			i, ok := index[name]
+			if !ok {
+				i = len(t.Engines)
+				index[name] = i
+				t.Engines = append(t.Engines, engines.MustGet(name))
+			}
+			pair[k] = i
+		}
+		t.Pairs = append(t.Pairs, pair)
 	}
+	start := time.Now()
+	stop, done := interruptible()
+	defer done()
+	t.Config.Stop = stop
+	res, err := comps.RunTournament(t)
+	if err != nil {
+		warn(err)
+		return
+	}
+	if res.Resumed > 0 {
+		fmt.Printf("%v games were read from %v\n", res.Resumed, t.Results)
+	}
+	saveReport(res.Matches...)
+	allFights := res.Matches
+	for _, fight := range allFights {
+		saveRecords(fight.Games...)
+		reportCrashes(fight.Crashes)
+		fmt.Println(fight)
+		fmt.Println("    ", fight.Stats)
+	}
+	showTimings(allFights...)
+	fmt.Println("championship took: ", time.Since(start))
+	// closest matches first
 	sort.Slice(allFights, func(i, j int) bool {
-		ires := math.Abs(allFights[i].White.Score - allFights[i].Black.Score)
-		jres := math.Abs(allFights[j].White.Score - allFights[j].Black.Score)
-		return ires < jres
+		return math.Abs(allFights[i].Stats.Elo) < math.Abs(allFights[j].Stats.Elo)
 	})
 	fmt.Println("----------------FINAL-RESULT-----------------")
 	for _, fight := range allFights {
 		fmt.Println(fight)
+		fmt.Println("    ", fight.Stats)
+	}
+}
+
+// interruptible stops a match on the first interrupt, the games being
+// played are finished and counted. A second interrupt quits as usual
+func interruptible() (<-chan struct{}, func()) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer signal.Stop(sig)
+		select {
+		case <-sig:
+			fmt.Println("\nstopping, the games being played are finished, interrupt again to quit now")
+			close(stop)
+		case <-done:
+		}
+	}()
+	return stop, func() { close(done) }
+}
+
+// reportCrashes shows the stack traces of the engines that panicked
+func reportCrashes(crashes []string) {
+	for _, crash := range crashes {
+		fmt.Fprintln(os.Stderr, crash)
+	}
+	if len(crashes) > 0 {
+		fmt.Printf("%v%v games crashed%v, the engine that panicked lost them\n",
+			colors.Red, len(crashes), colors.Reset)
 	}
 }
 
@@ -343,6 +1346,132 @@ func test() {
 			fmt.Println("CompareGens failed")
 		}
 	}
+
+	err = testBot()
+	if err != "" {
+		fmt.Printf("TestBot %vfailed%v: %v\n", colors.Red, colors.Reset, err)
+	}
+
+	err = testDepth()
+	if err != "" {
+		fmt.Printf("TestDepth %vfailed%v: %v\n", colors.Red, colors.Reset, err)
+	}
+
+	err = testServerDepth()
+	if err != "" {
+		fmt.Printf("TestServerDepth %vfailed%v: %v\n", colors.Red, colors.Reset, err)
+	}
+}
+
+// testServerDepth asks the JSON API for engine moves deeper
+// than the engine can search, which must be refused
+func testServerDepth() string {
+	httpSrv := httptest.NewServer(server.New("typeb(depth=2,breadth=3/3/3,eval=material)"))
+	defer httpSrv.Close()
+	post := func(path, body string) (int, string) {
+		res, err := http.Post(httpSrv.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			return 0, err.Error()
+		}
+		defer res.Body.Close()
+		text, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(text)
+	}
+	status, body := post("/api/games", `{"layout": "standard"}`)
+	if status != http.StatusCreated {
+		return fmt.Sprintf("creating a game: %v %v", status, body)
+	}
+	status, body = post("/api/games/1/engine", `{"depth": 50}`)
+	if status != http.StatusBadRequest {
+		return fmt.Sprintf("depth 50 gave %v instead of %v: %v", status, http.StatusBadRequest, body)
+	}
+	status, body = post("/api/games/1/engine", `{"depth": 2}`)
+	if status != http.StatusOK {
+		return fmt.Sprintf("depth 2 gave %v: %v", status, body)
+	}
+	return ""
+}
+
+// testDepth searches past the breadths of a typeb engine,
+// which must be clamped to the deepest it can search
+func testDepth() (output string) {
+	eng := engines.MustGet("typeb(depth=2,breadth=3/3/3,eval=material)")
+	if ifaces.CheckDepth(eng, 3) == nil {
+		return "depth 3 was accepted with 3 breadths"
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			output = fmt.Sprint("searching past the breadths panicked: ", r)
+		}
+	}()
+	an := eng.Analyse(game.InitialGame(game.InitialBoard()), ifaces.Limits{Depth: 6})
+	if an.Depth != 2 {
+		return fmt.Sprintf("searched depth %v instead of 2", an.Depth)
+	}
+	return ""
+}
+
+// testBot plays the bot against a random engine on a fake server
+func testBot() string {
+	srv := fakeserver.New("simplebot")
+	srv.Opponent = engines.MustGet("random")
+	httpSrv := httptest.NewServer(srv)
+	defer httpSrv.Close()
+	client := bot.New(bot.Config{
+		URL:      httpSrv.URL,
+		Token:    "test",
+		Engine:   engines.MustGet("alphabeta(depth=2,eval=psqt)"),
+		MaxGames: 1,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Run(ctx)
+	}()
+
+	_, answer := srv.Challenge(bot.Challenge{Variant: bot.Variant{Key: "standard"}})
+	if reason := <-answer; reason != "variant" {
+		return "standard chess was not declined for its variant: " + reason
+	}
+	for _, color := range []string{"white", "black"} {
+		fen := game.InitialGame(game.ShuffledBoard()).FEN()
+		id, answer := srv.Challenge(bot.Challenge{
+			Variant:    bot.Variant{Key: bot.DefaultVariant},
+			Color:      color,
+			InitialFEN: fen,
+		})
+		if reason := <-answer; reason != "accepted" {
+			return "challenge from " + fen + " was declined: " + reason
+		}
+		status, _, moves := srv.Result(id, time.Minute)
+		if status != "mate" && status != "draw" {
+			return fmt.Sprintf("game %v ended as %v after %v moves", id, status, moves)
+		}
+	}
+	srv.Close()
+	err := <-done
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func runBot(eng ifaces.Engine) {
+	token := *botToken
+	if token == "" {
+		token = os.Getenv("LICHESS_TOKEN")
+	}
+	client := bot.New(bot.Config{
+		URL:    strings.TrimSuffix(*botURL, "/"),
+		Token:  token,
+		Engine: eng,
+		Log:    func(s string) { fmt.Println(s) },
+	})
+	err := client.Run(context.Background())
+	if err != nil {
+		fatal(err)
+	}
 }
 
 func showAttacked(cli *cliState) {
@@ -386,3 +1515,22 @@ func showDefended(cli *cliState) {
 	}
 	fmt.Println(cli.Curr.Board.Show(hls))
 }
+
+func generateTablebases(list string, dir string) {
+	if dir == "" {
+		dir = "tables"
+	}
+	set := tablebase.NewSet()
+	for _, name := range strings.Split(list, ",") {
+		m, err := tablebase.ParseMaterial(strings.TrimSpace(name))
+		if err != nil {
+			fatal(err)
+		}
+		set.Generate(m, func(s string) { fmt.Println(s) })
+	}
+	err := set.Save(dir)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("saved %v tables to %v\n", len(set.Tables()), dir)
+}
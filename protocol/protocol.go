@@ -0,0 +1,350 @@
+/*
+Package protocol implements a line based engine protocol modelled on UCI,
+so that GUIs, bots and scripts can drive the engine. Differences from UCI:
+
+	positions are given as FEN without castling or en passant
+	(those fields are accepted and ignored), promotions are
+	always to queens and a trailing piece in a move is ignored
+
+	scores are in centipawns from the side to move, a captured
+	king is worth about 10000
+
+	"position shuffled" starts from a random shuffled layout,
+	the FEN is printed with "info string"
+*/
+package protocol
+
+import (
+	"chess/engines"
+	"chess/game"
+	ifaces "chess/interfaces"
+
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const name = "simplified chess"
+const author = "padeir0"
+
+// Server reads commands from its input and answers on its output
+type Server struct {
+	// the engine after book and tablebase wrappers
+	engine ifaces.Engine
+
+	spec       string
+	bookFile   string
+	tbDir      string
+	depth      int
+	position   *game.GameState
+	searchStop chan struct{}
+	searchDone chan struct{}
+
+	out   io.Writer
+	outMu sync.Mutex
+}
+
+// NewServer builds the engine from its name or spec, with the
+// opening book and tablebases if given, they are also options
+func NewServer(spec, bookFile, tbDir string, out io.Writer) (*Server, error) {
+	this := &Server{
+		spec:     spec,
+		bookFile: bookFile,
+		tbDir:    tbDir,
+		position: game.InitialGame(game.InitialBoard()),
+		out:      out,
+	}
+	err := this.build()
+	if err != nil {
+		return nil, err
+	}
+	return this, nil
+}
+
+// Run serves commands until "quit" or the end of the input
+func (this *Server) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" {
+			this.stop()
+			return nil
+		}
+		this.eval(fields)
+	}
+	this.stop()
+	return scanner.Err()
+}
+
+func (this *Server) send(format string, args ...any) {
+	this.outMu.Lock()
+	defer this.outMu.Unlock()
+	fmt.Fprintf(this.out, format+"\n", args...)
+}
+
+func (this *Server) eval(fields []string) {
+	switch fields[0] {
+	case "uci":
+		this.send("id name %v", name)
+		this.send("id author %v", author)
+		this.send("option name Engine type string default %v", this.spec)
+		this.send("option name Depth type spin default 0 min 0 max 32")
+		this.send("option name Book type string default %v", orEmpty(this.bookFile))
+		this.send("option name Tablebases type string default %v", orEmpty(this.tbDir))
+		this.send("uciok")
+	case "isready":
+		this.send("readyok")
+	case "ucinewgame":
+		this.stop()
+		this.position = game.InitialGame(game.InitialBoard())
+	case "setoption":
+		this.stop()
+		this.setOption(fields[1:])
+	case "position":
+		this.stop()
+		this.setPosition(fields[1:])
+	case "go":
+		this.stop()
+		this.search(fields[1:])
+	case "stop":
+		this.stop()
+	case "d":
+		this.send("info string %v", this.position.FEN())
+	default:
+		this.send("info string unknown command: %v", fields[0])
+	}
+}
+
+func orEmpty(s string) string {
+	if s == "" {
+		return "<empty>"
+	}
+	return s
+}
+
+// setoption name <name> value <value>, values may have spaces
+func (this *Server) setOption(fields []string) {
+	if len(fields) < 2 || fields[0] != "name" {
+		this.send("info string expected: setoption name <name> [value <value>]")
+		return
+	}
+	option := fields[1]
+	value := ""
+	if len(fields) > 3 && fields[2] == "value" {
+		value = strings.Join(fields[3:], " ")
+	}
+	if value == "<empty>" {
+		value = ""
+	}
+	switch strings.ToLower(option) {
+	case "engine":
+		this.spec = value
+	case "depth":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			this.send("info string invalid depth: %v", value)
+			return
+		}
+		err = ifaces.CheckDepth(this.engine, n)
+		if err != nil {
+			this.send("info string %v", err)
+			return
+		}
+		this.depth = n
+		return
+	case "book":
+		this.bookFile = value
+	case "tablebases":
+		this.tbDir = value
+	default:
+		this.send("info string unknown option: %v", option)
+		return
+	}
+	err := this.build()
+	if err != nil {
+		this.send("info string %v", err)
+	}
+}
+
+// build creates the engine from the options
+func (this *Server) build() error {
+	eng, err := engines.Load(this.spec, this.bookFile, this.tbDir)
+	if err != nil {
+		return err
+	}
+	this.engine = eng
+	return nil
+}
+
+// position (startpos | shuffled | fen <fen>) [moves <move>...]
+func (this *Server) setPosition(fields []string) {
+	if len(fields) == 0 {
+		this.send("info string expected: position (startpos | shuffled | fen <fen>) [moves ...]")
+		return
+	}
+	var g *game.GameState
+	rest := fields[1:]
+	switch fields[0] {
+	case "startpos":
+		g = game.InitialGame(game.InitialBoard())
+	case "shuffled":
+		g = game.InitialGame(game.ShuffledBoard())
+		this.send("info string %v", g.FEN())
+	case "fen":
+		end := len(fields)
+		for i, f := range fields {
+			if f == "moves" {
+				end = i
+				break
+			}
+		}
+		var err error
+		g, err = game.ParseFEN(strings.Join(fields[1:end], " "))
+		if err != nil {
+			this.send("info string %v", err)
+			return
+		}
+		rest = fields[end:]
+	default:
+		this.send("info string invalid position: %v", fields[0])
+		return
+	}
+	if len(rest) > 0 && rest[0] == "moves" {
+		for _, mv := range rest[1:] {
+			from, to, ok := game.ParseCoord(mv)
+			if ok {
+				ok, _ = g.Move(from, to)
+			}
+			if !ok {
+				this.send("info string illegal move: %v", mv)
+				return
+			}
+		}
+	}
+	this.position = g
+}
+
+// go [depth <n>] [movetime <ms>] [wtime <ms>] [btime <ms>]
+// [winc <ms>] [binc <ms>] [movestogo <n>] [infinite]
+func (this *Server) search(fields []string) {
+	g := this.position.Copy()
+	stop := make(chan struct{})
+	lim := ifaces.Limits{Stop: stop, Depth: this.depth}
+	var wtime, btime, winc, binc time.Duration
+	infinite := false
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == "infinite" {
+			infinite = true
+			continue
+		}
+		if i+1 >= len(fields) {
+			this.send("info string missing value for %v", fields[i])
+			return
+		}
+		n, err := strconv.Atoi(fields[i+1])
+		if err != nil || n < 0 {
+			this.send("info string invalid value for %v: %v", fields[i], fields[i+1])
+			return
+		}
+		ms := time.Duration(n) * time.Millisecond
+		switch fields[i] {
+		case "depth":
+			err = ifaces.CheckDepth(this.engine, n)
+			if err != nil {
+				this.send("info string %v", err)
+				return
+			}
+			lim.Depth = n
+		case "movetime":
+			lim.MoveTime = ms
+		case "wtime":
+			wtime = ms
+		case "btime":
+			btime = ms
+		case "winc":
+			winc = ms
+		case "binc":
+			binc = ms
+		case "movestogo":
+			lim.MovesToGo = n
+		default:
+			this.send("info string unknown parameter: %v", fields[i])
+			return
+		}
+		i++
+	}
+	if g.BlackTurn {
+		lim.Time, lim.Increment = btime, binc
+	} else {
+		lim.Time, lim.Increment = wtime, winc
+	}
+
+	reported := false
+	lim.Info = func(an ifaces.Analysis) {
+		reported = true
+		this.info(g, an)
+	}
+	done := make(chan struct{})
+	this.searchStop = stop
+	this.searchDone = done
+	eng := this.engine
+	go func() {
+		defer close(done)
+		best := this.bestMove(g, eng, lim, &reported)
+		// an infinite search holds its move until told to stop
+		if infinite {
+			<-stop
+		}
+		this.send("%v", best)
+	}()
+}
+
+// bestMove searches and returns the bestmove line
+func (this *Server) bestMove(g *game.GameState, eng ifaces.Engine, lim ifaces.Limits, reported *bool) string {
+	if g.IsOver {
+		return "bestmove 0000"
+	}
+	an := eng.Analyse(g, lim)
+	if an.Stopped || an.Move == *game.NullMove {
+		return "bestmove 0000"
+	}
+	if !*reported {
+		this.info(g, an)
+	}
+	if len(an.PV) > 1 {
+		return fmt.Sprintf("bestmove %v ponder %v", an.Move.Coord(), an.PV[1].Coord())
+	}
+	return "bestmove " + an.Move.Coord()
+}
+
+// info converts the score to the point of view of the side to move
+func (this *Server) info(g *game.GameState, an ifaces.Analysis) {
+	score := an.Score
+	if g.BlackTurn {
+		score = -score
+	}
+	pv := make([]string, len(an.PV))
+	for i := range an.PV {
+		pv[i] = an.PV[i].Coord()
+	}
+	this.send("info depth %v score cp %v nodes %v time %v pv %v",
+		an.Depth, score, an.Nodes, an.Time.Milliseconds(), strings.Join(pv, " "))
+}
+
+// stop aborts the running search and waits for its bestmove
+func (this *Server) stop() {
+	if this.searchStop == nil {
+		return
+	}
+	close(this.searchStop)
+	<-this.searchDone
+	this.searchStop = nil
+	this.searchDone = nil
+}
@@ -11,22 +11,22 @@ import (
 var _ ifaces.BasicSearch = BestMove
 var _ = fmt.Sprintf("please stop bothering me, Go")
 
-func BestMove(g *game.GameState, eval ifaces.Evaluator, depth int) game.Move {
+func BestMove(g *game.GameState, eval ifaces.Evaluator, depth int) ifaces.Analysis {
 	nodes = 0
 	n := &Node{
 		Move:  *game.NullMove,
 		Score: 314159,
 	}
 	newG := g.Copy()
-	bestMove := alphabeta(newG, n, MinusInf, PlusInf, depth, eval)
+	alphabeta(newG, n, MinusInf, PlusInf, depth, eval)
 
 	//fmt.Println("nodes: ", nodes)
 
 	//fmt.Println(n.NextMoves(g.BlackTurn))
-	//fmt.Println("Best Move: ", bestMove.Move)
-	//fmt.Println("Best Score: ", bestMove.Score)
+	//fmt.Println("Best Move: ", n.Next.Move)
+	//fmt.Println("Best Score: ", n.Score)
 
-	return bestMove.Move
+	return n.Analysis()
 }
 
 var nodes = 0
@@ -38,9 +38,11 @@ func alphabeta(g *game.GameState, n *Node, alpha, beta int, depth int, eval ifac
 		return n
 	}
 	if g.BlackTurn {
-		return minimizingPlayer(g, n, alpha, beta, depth, eval)
+		n.Next = minimizingPlayer(g, n, alpha, beta, depth, eval)
+	} else {
+		n.Next = maximizingPlayer(g, n, alpha, beta, depth, eval)
 	}
-	return maximizingPlayer(g, n, alpha, beta, depth, eval)
+	return n.Next
 }
 
 func maximizingPlayer(g *game.GameState, n *Node, alpha, beta int, depth int, eval ifaces.Evaluator) *Node {
@@ -2,6 +2,7 @@ package common
 
 import (
 	"chess/game"
+	ifaces "chess/interfaces"
 	"fmt"
 )
 
@@ -40,6 +41,32 @@ type Node struct {
 	Score int
 
 	Leaves []*Node
+
+	// best continuation found from this node, if any
+	Next *Node
+}
+
+// PV returns the principal variation found below this node
+func (this *Node) PV() []game.Move {
+	output := []game.Move{}
+	for n := this.Next; n != nil; n = n.Next {
+		output = append(output, n.Move)
+	}
+	return output
+}
+
+// Analysis packs the result of a search rooted at this node
+func (this *Node) Analysis() ifaces.Analysis {
+	pv := this.PV()
+	if len(pv) == 0 {
+		return ifaces.Analysis{Move: *game.NullMove, Score: this.Score, Scored: true}
+	}
+	return ifaces.Analysis{
+		Move:   pv[0],
+		Score:  this.Score,
+		Scored: true,
+		PV:     pv,
+	}
 }
 
 func (this *Node) AddLeaf(n *Node) {
@@ -11,19 +11,19 @@ import (
 var _ ifaces.BasicSearch = BestMove
 var _ = fmt.Sprintf(":)")
 
-func BestMove(g *game.GameState, eval ifaces.Evaluator, depth int) game.Move {
+func BestMove(g *game.GameState, eval ifaces.Evaluator, depth int) ifaces.Analysis {
 	n := &Node{
 		Move:  *game.NullMove,
 		Score: 314159,
 	}
 	newG := g.Copy()
-	bestMove := miniMax(newG, n, depth, eval)
+	miniMax(newG, n, depth, eval)
 
 	//fmt.Println(n.NextMoves(g.BlackTurn))
-	//fmt.Println("Best Move: ", bestMove.Move)
-	//fmt.Println("Best Score: ", bestMove.Score)
+	//fmt.Println("Best Move: ", n.Next.Move)
+	//fmt.Println("Best Score: ", n.Score)
 
-	return bestMove.Move
+	return n.Analysis()
 }
 
 func miniMax(g *game.GameState, n *Node, depth int, eval ifaces.Evaluator) *Node {
@@ -32,9 +32,11 @@ func miniMax(g *game.GameState, n *Node, depth int, eval ifaces.Evaluator) *Node
 		return n
 	}
 	if g.BlackTurn {
-		return minimizingPlayer(g, n, depth, eval)
+		n.Next = minimizingPlayer(g, n, depth, eval)
+	} else {
+		n.Next = maximizingPlayer(g, n, depth, eval)
 	}
-	return maximizingPlayer(g, n, depth, eval)
+	return n.Next
 }
 
 func maximizingPlayer(g *game.GameState, n *Node, depth int, eval ifaces.Evaluator) *Node {
@@ -8,19 +8,22 @@ import (
 	"fmt"
 )
 
-func BestMove(g *game.GameState, eval ifaces.Evaluator, depth int) game.Move {
+var _ ifaces.BasicSearch = BestMove
+var _ = fmt.Sprintf(":)")
+
+func BestMove(g *game.GameState, eval ifaces.Evaluator, depth int) ifaces.Analysis {
 	n := &Node{
 		Move:  *game.NullMove,
 		Score: 314159,
 	}
 	newG := g.Copy()
-	bestScore, bestNode := negaMax(newG, n, depth, eval)
+	negaMax(newG, n, depth, eval)
 
-	fmt.Sprintln(n.NextMoves(g.BlackTurn))
-	fmt.Sprintln("Best Move: ", bestNode.Move)
-	fmt.Sprintln("Best Score: ", bestScore, bestNode.Score)
+	//fmt.Println(n.NextMoves(g.BlackTurn))
+	//fmt.Println("Best Move: ", n.Next.Move)
+	//fmt.Println("Best Score: ", n.Score)
 
-	return bestNode.Move
+	return n.Analysis()
 }
 
 func negaMax(g *game.GameState, n *Node, depth int, eval ifaces.Evaluator) (int, *Node) {
@@ -48,6 +51,7 @@ func negaMax(g *game.GameState, n *Node, depth int, eval ifaces.Evaluator) (int,
 		mv, ok = mg.Next()
 	}
 	n.Score = bestNode.Score
+	n.Next = bestNode
 	return bestScore, bestNode
 }
 
@@ -11,21 +11,21 @@ import (
 var _ ifaces.ExtendedSearch = BestMove
 var _ = fmt.Sprintf(":)")
 
-func BestMove(g *game.GameState, eval ifaces.Evaluator, qdepth, depth int) game.Move {
+func BestMove(g *game.GameState, eval ifaces.Evaluator, qdepth, depth int) ifaces.Analysis {
 	nodes = 0
 	qnodes = 0
 	n := &Node{
 		Move:  *game.NullMove,
 		Score: 314159,
 	}
-	bestMove := alphabeta(g, n, MinusInf, PlusInf, qdepth, depth, eval)
+	alphabeta(g, n, MinusInf, PlusInf, qdepth, depth, eval)
 
 	//fmt.Println("nodes: ", nodes, "qnodes: ", qnodes)
 	//fmt.Println(n.NextMoves(g.BlackTurn))
-	//fmt.Println("Best Move: ", bestMove.Move)
-	//fmt.Println("Best Score: ", bestMove.Score)
+	//fmt.Println("Best Move: ", n.Next.Move)
+	//fmt.Println("Best Score: ", n.Score)
 
-	return bestMove.Move
+	return n.Analysis()
 }
 
 var nodes = 0
@@ -41,9 +41,11 @@ func alphabeta(g *game.GameState, n *Node, alpha, beta, qdepth, depth int, eval
 		return n
 	}
 	if g.BlackTurn {
-		return minimizingPlayer(g, n, alpha, beta, qdepth, depth, eval)
+		n.Next = minimizingPlayer(g, n, alpha, beta, qdepth, depth, eval)
+	} else {
+		n.Next = maximizingPlayer(g, n, alpha, beta, qdepth, depth, eval)
 	}
-	return maximizingPlayer(g, n, alpha, beta, qdepth, depth, eval)
+	return n.Next
 }
 
 func maximizingPlayer(g *game.GameState, n *Node, alpha, beta, qdepth, depth int, eval ifaces.Evaluator) *Node {
@@ -113,10 +115,16 @@ func quiescence(g *game.GameState, n *Node, alpha, beta, depth, qdepth int, eval
 		n.Score = eval(g, depth+qdepth)
 		return n
 	}
+	var best *Node
 	if g.BlackTurn {
-		return quiesc_minimize(g, n, alpha, beta, depth, qdepth, eval)
+		best = quiesc_minimize(g, n, alpha, beta, depth, qdepth, eval)
+	} else {
+		best = quiesc_maximize(g, n, alpha, beta, depth, qdepth, eval)
 	}
-	return quiesc_maximize(g, n, alpha, beta, depth, qdepth, eval)
+	if best != n {
+		n.Next = best
+	}
+	return best
 }
 
 func quiesc_minimize(g *game.GameState, n *Node, alpha, beta, depth, qdepth int, eval ifaces.Evaluator) *Node {
@@ -9,18 +9,18 @@ import (
 
 var _ ifaces.BasicSearch = BestMove
 
-func BestMove(g *game.GameState, eval ifaces.Evaluator, depth int) game.Move {
+func BestMove(g *game.GameState, eval ifaces.Evaluator, depth int) ifaces.Analysis {
 	newG := g.Copy()
 	mvgen := movegen.NewMoveGenerator(newG)
 	captures := movegen.ConsumeAllCaptures(mvgen)
 	if len(captures) > 0 {
 		i := rand.Intn(len(captures))
-		return captures[i]
+		return ifaces.Analysis{Move: captures[i], PV: captures[i : i+1]}
 	}
 	quiets := movegen.ConsumeAllQuiet(mvgen)
 	if len(quiets) > 0 {
 		i := rand.Intn(len(quiets))
-		return quiets[i]
+		return ifaces.Analysis{Move: quiets[i], PV: quiets[i : i+1]}
 	}
-	return *game.NullMove
+	return ifaces.Analysis{Move: *game.NullMove}
 }
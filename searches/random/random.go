@@ -9,10 +9,10 @@ import (
 
 var _ ifaces.BasicSearch = BestMove
 
-func BestMove(g *game.GameState, eval ifaces.Evaluator, depth int) game.Move {
+func BestMove(g *game.GameState, eval ifaces.Evaluator, depth int) ifaces.Analysis {
 	newG := g.Copy()
 	mvgen := movegen.NewMoveGenerator(newG)
 	moves := movegen.ConsumeAll(mvgen)
 	i := rand.Intn(len(moves))
-	return moves[i]
+	return ifaces.Analysis{Move: moves[i], PV: moves[i : i+1]}
 }
@@ -14,7 +14,7 @@ var _ = fmt.Sprintf(":)")
 
 var breadth = 5
 
-func BestMove(g *game.GameState, eval ifaces.Evaluator, depth int, breadth []int) game.Move {
+func BestMove(g *game.GameState, eval ifaces.Evaluator, depth int, breadth []int) ifaces.Analysis {
 	n := &Node{
 		Move:  *game.NullMove,
 		Score: 314159,
@@ -22,17 +22,11 @@ func BestMove(g *game.GameState, eval ifaces.Evaluator, depth int, breadth []int
 	newG := g.Copy()
 	typeB(newG, n, depth, breadth, eval)
 
-	if len(n.Leaves) == 0 {
-		return *game.NullMove
-	}
-
-	bestMove := n.Leaves[0]
-
 	// fmt.Println(n.NextMoves(g.BlackTurn))
-	// fmt.Println("Best Move: ", bestMove.Move)
-	// fmt.Println("Best Score: ", bestMove.Score)
+	// fmt.Println("Best Move: ", n.Next.Move)
+	// fmt.Println("Best Score: ", n.Score)
 
-	return bestMove.Move
+	return n.Analysis()
 }
 
 /*
@@ -85,6 +79,7 @@ func typeB(g *game.GameState, n *Node, depth int, breadth []int, eval ifaces.Eva
 	}
 	best := n.Leaves[0]
 	n.Score = best.Score
+	n.Next = best
 	return n
 }
 
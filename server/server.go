@@ -0,0 +1,518 @@
+// HTTP server exposing games and engines as a JSON API
+package server
+
+import (
+	"chess/engines"
+	"chess/game"
+	"chess/game/record"
+	rs "chess/game/result"
+	ifaces "chess/interfaces"
+	movegen "chess/movegen/segregated"
+	"chess/stream"
+
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed ui
+var ui embed.FS
+
+// Server keeps the games in memory, they are lost on restart
+type Server struct {
+	// engine used when a request doesn't name one
+	DefaultEngine string
+	// the longest an engine may think, as it holds the game
+	// meanwhile. Also the time of requests that give none
+	MaxMoveTime time.Duration
+	// moves of the games played here, and of anything
+	// else published, are streamed on /api/events
+	Events *stream.Broker
+
+	games  map[string]*liveGame
+	lastID int
+	sync.Mutex
+}
+
+func New(defaultEngine string) *Server {
+	return &Server{
+		DefaultEngine: defaultEngine,
+		MaxMoveTime:   10 * time.Second,
+		Events:        stream.NewBroker(),
+		games:         map[string]*liveGame{},
+	}
+}
+
+type liveGame struct {
+	ID    string
+	Start *game.GameState
+	Curr  *game.GameState
+	// player names, engines sign the side they play
+	White, Black string
+
+	sync.Mutex
+}
+
+/*
+Routes:
+
+	POST /api/games                 {"layout": "standard"|"shuffled", "fen": "..."}
+	GET  /api/games/{id}
+	POST /api/games/{id}/moves      {"move": "e2e3"}
+	POST /api/games/{id}/engine     {"engine": "alphabeta(depth=4)", "depth": 4, "movetime": 500}
+	POST /api/games/{id}/undo       {"plies": 2}
+	GET  /api/games/{id}/legal
+	GET  /api/games/{id}/record
+	GET  /api/engines
+	GET  /api/events                Server-Sent Events of running games
+
+Errors come as {"error": "..."} with a 4xx or 5xx status,
+anything outside of /api/ is the browser UI
+*/
+func (this *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	if parts[0] != "api" {
+		static, _ := fs.Sub(ui, "ui")
+		http.FileServer(http.FS(static)).ServeHTTP(w, r)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "events" {
+		if method(w, r, http.MethodGet) {
+			this.Events.ServeHTTP(w, r)
+		}
+		return
+	}
+	if len(parts) == 2 && parts[1] == "engines" {
+		if method(w, r, http.MethodGet) {
+			reply(w, engineList())
+		}
+		return
+	}
+	if len(parts) < 2 || parts[1] != "games" {
+		fail(w, http.StatusNotFound, errors.New("not found: "+r.URL.Path))
+		return
+	}
+	if len(parts) == 2 {
+		if !method(w, r, http.MethodPost) {
+			return
+		}
+		this.createGame(w, r)
+		return
+	}
+	g := this.game(parts[2])
+	if g == nil {
+		fail(w, http.StatusNotFound, errors.New("no game with id "+parts[2]))
+		return
+	}
+	action := ""
+	if len(parts) == 4 {
+		action = parts[3]
+	} else if len(parts) > 4 {
+		fail(w, http.StatusNotFound, errors.New("not found: "+r.URL.Path))
+		return
+	}
+	switch action {
+	case "":
+		if method(w, r, http.MethodGet) {
+			g.Lock()
+			defer g.Unlock()
+			reply(w, g.view())
+		}
+	case "moves":
+		if method(w, r, http.MethodPost) {
+			this.move(w, r, g)
+		}
+	case "engine":
+		if method(w, r, http.MethodPost) {
+			this.engineMove(w, r, g)
+		}
+	case "undo":
+		if method(w, r, http.MethodPost) {
+			this.undo(w, r, g)
+		}
+	case "legal":
+		if method(w, r, http.MethodGet) {
+			g.Lock()
+			defer g.Unlock()
+			reply(w, legalMoves(g.Curr))
+		}
+	case "record":
+		if method(w, r, http.MethodGet) {
+			g.Lock()
+			defer g.Unlock()
+			reply(w, record.New(g.White, g.Black, g.Start, g.Curr))
+		}
+	default:
+		fail(w, http.StatusNotFound, errors.New("not found: "+r.URL.Path))
+	}
+}
+
+func method(w http.ResponseWriter, r *http.Request, m string) bool {
+	if r.Method != m {
+		w.Header().Set("Allow", m)
+		fail(w, http.StatusMethodNotAllowed, errors.New(r.Method+" not allowed, use "+m))
+		return false
+	}
+	return true
+}
+
+func reply(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func fail(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+func decode(r *http.Request, v any) error {
+	if r.ContentLength == 0 {
+		return nil
+	}
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		return errors.New("invalid request body: " + err.Error())
+	}
+	return nil
+}
+
+func (this *Server) game(id string) *liveGame {
+	this.Lock()
+	defer this.Unlock()
+	return this.games[id]
+}
+
+type createRequest struct {
+	Layout string `json:"layout"`
+	FEN    string `json:"fen"`
+	// played from the starting position, so that
+	// exported games may be imported again
+	Moves []string `json:"moves"`
+}
+
+func (this *Server) createGame(w http.ResponseWriter, r *http.Request) {
+	req := createRequest{}
+	err := decode(r, &req)
+	if err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	var start *game.GameState
+	switch {
+	case req.FEN != "":
+		start, err = game.ParseFEN(req.FEN)
+		if err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+	case req.Layout == "" || req.Layout == "standard":
+		start = game.InitialGame(game.InitialBoard())
+	case req.Layout == "shuffled":
+		start = game.InitialGame(game.ShuffledBoard())
+	default:
+		fail(w, http.StatusBadRequest, errors.New("layout must be standard or shuffled"))
+		return
+	}
+	curr := start.Copy()
+	for _, mv := range req.Moves {
+		from, to, ok := game.ParseCoord(mv)
+		if ok {
+			ok, _ = curr.Move(from, to)
+		}
+		if !ok {
+			fail(w, http.StatusBadRequest, errors.New("illegal move: "+mv))
+			return
+		}
+	}
+	this.Lock()
+	this.lastID++
+	g := &liveGame{
+		ID:    strconv.Itoa(this.lastID),
+		Start: start,
+		Curr:  curr,
+		White: "player",
+		Black: "player",
+	}
+	this.games[g.ID] = g
+	this.Unlock()
+	this.publish(g, stream.Event{Kind: stream.Start})
+
+	w.WriteHeader(http.StatusCreated)
+	reply(w, g.view())
+}
+
+type moveRequest struct {
+	Move string `json:"move"`
+}
+
+func (this *Server) move(w http.ResponseWriter, r *http.Request, g *liveGame) {
+	req := moveRequest{}
+	err := decode(r, &req)
+	if err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	from, to, ok := game.ParseCoord(req.Move)
+	if !ok {
+		fail(w, http.StatusBadRequest, errors.New("invalid move: "+req.Move))
+		return
+	}
+	g.Lock()
+	defer g.Unlock()
+	if g.Curr.IsOver {
+		fail(w, http.StatusConflict, errors.New("the game is over"))
+		return
+	}
+	ok, _ = g.Curr.Move(from, to)
+	if !ok {
+		fail(w, http.StatusUnprocessableEntity, errors.New("illegal move: "+req.Move))
+		return
+	}
+	this.publishMove(g, req.Move, 0)
+	reply(w, g.view())
+}
+
+type undoRequest struct {
+	Plies int `json:"plies"`
+}
+
+// undo takes back moves, but never past the start of the game
+func (this *Server) undo(w http.ResponseWriter, r *http.Request, g *liveGame) {
+	req := undoRequest{Plies: 1}
+	err := decode(r, &req)
+	if err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	g.Lock()
+	defer g.Unlock()
+	played := g.Curr.Moves.Len() - g.Start.Moves.Len()
+	if played == 0 {
+		fail(w, http.StatusConflict, errors.New("nothing to undo"))
+		return
+	}
+	if req.Plies < 1 || req.Plies > played {
+		fail(w, http.StatusConflict, errors.New("can only undo from 1 to "+strconv.Itoa(played)+" plies"))
+		return
+	}
+	for i := 0; i < req.Plies; i++ {
+		g.Curr.UnMove()
+	}
+	reply(w, g.view())
+}
+
+type engineRequest struct {
+	Engine string `json:"engine"`
+	// overrides the depth of the engine
+	Depth int `json:"depth"`
+	// milliseconds, the search deepens iteratively until then,
+	// at most and by default Server.MaxMoveTime
+	MoveTime int `json:"movetime"`
+}
+
+type engineReply struct {
+	Engine string   `json:"engine"`
+	Move   string   `json:"move"`
+	Score  int      `json:"score"`
+	PV     []string `json:"pv"`
+	Depth  int      `json:"depth"`
+	Nodes  int      `json:"nodes"`
+	TimeMs int64    `json:"time_ms"`
+	Game   gameView `json:"game"`
+}
+
+// engineMove holds the game while the engine thinks,
+// other requests on the game wait for it
+func (this *Server) engineMove(w http.ResponseWriter, r *http.Request, g *liveGame) {
+	req := engineRequest{}
+	err := decode(r, &req)
+	if err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Engine == "" {
+		req.Engine = this.DefaultEngine
+	}
+	if req.Depth < 0 || req.MoveTime < 0 {
+		fail(w, http.StatusBadRequest, errors.New("depth and movetime can't be negative"))
+		return
+	}
+	eng, err := engines.Get(req.Engine)
+	if err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	err = ifaces.CheckDepth(eng, req.Depth)
+	if err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	g.Lock()
+	defer g.Unlock()
+	if g.Curr.IsOver {
+		fail(w, http.StatusConflict, errors.New("the game is over"))
+		return
+	}
+	moveTime := time.Duration(req.MoveTime) * time.Millisecond
+	if moveTime == 0 || moveTime > this.MaxMoveTime {
+		moveTime = this.MaxMoveTime
+	}
+	an := eng.Analyse(g.Curr, ifaces.Limits{
+		Depth:    req.Depth,
+		MoveTime: moveTime,
+	})
+	if an.Move == *game.NullMove {
+		fail(w, http.StatusInternalServerError, errors.New(eng.String()+" made no move"))
+		return
+	}
+	black := g.Curr.BlackTurn
+	ok, _ := g.Curr.Move(an.Move.From, an.Move.To)
+	if !ok {
+		fail(w, http.StatusInternalServerError, errors.New(eng.String()+" made an illegal move: "+an.Move.Coord()))
+		return
+	}
+	if black {
+		g.Black = eng.String()
+	} else {
+		g.White = eng.String()
+	}
+	this.publishMove(g, an.Move.Coord(), an.Score)
+	reply(w, engineReply{
+		Engine: eng.String(),
+		Move:   an.Move.Coord(),
+		Score:  an.Score,
+		PV:     coords(an.PV),
+		Depth:  an.Depth,
+		Nodes:  an.Nodes,
+		TimeMs: an.Time.Milliseconds(),
+		Game:   g.view(),
+	})
+}
+
+// publishMove tells about the move, and the end of the game if it's over
+func (this *Server) publishMove(g *liveGame, move string, score int) {
+	this.publish(g, stream.Event{
+		Kind:  stream.Move,
+		Ply:   g.Curr.Moves.Len(),
+		Move:  move,
+		Score: score,
+	})
+	if g.Curr.IsOver {
+		this.publish(g, stream.Event{
+			Kind:   stream.End,
+			Result: g.Curr.Result,
+			Reason: g.Curr.Reason,
+		})
+	}
+}
+
+func (this *Server) publish(g *liveGame, e stream.Event) {
+	e.Game = "web." + g.ID
+	e.White = g.White
+	e.Black = g.Black
+	e.FEN = g.Curr.FEN()
+	this.Events.Publish(e)
+}
+
+type gameView struct {
+	ID     string    `json:"id"`
+	FEN    string    `json:"fen"`
+	Turn   string    `json:"turn"`
+	Moves  []string  `json:"moves"`
+	White  string    `json:"white"`
+	Black  string    `json:"black"`
+	Over   bool      `json:"over"`
+	Result rs.Result `json:"result"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+func (this *liveGame) view() gameView {
+	turn := "white"
+	if this.Curr.BlackTurn {
+		turn = "black"
+	}
+	moves := this.Curr.Moves.List()[this.Start.Moves.Len():]
+	return gameView{
+		ID:     this.ID,
+		FEN:    this.Curr.FEN(),
+		Turn:   turn,
+		Moves:  coords(moves),
+		White:  this.White,
+		Black:  this.Black,
+		Over:   this.Curr.IsOver,
+		Result: this.Curr.Result,
+		Reason: this.Curr.Reason,
+	}
+}
+
+type legalMove struct {
+	Move    string `json:"move"`
+	Capture bool   `json:"capture"`
+}
+
+func legalMoves(g *game.GameState) []legalMove {
+	output := []legalMove{}
+	if g.IsOver {
+		return output
+	}
+	captures := movegen.NewMoveGenerator(g.Copy())
+	for _, mv := range movegen.ConsumeAllCaptures(captures) {
+		output = append(output, legalMove{Move: mv.Coord(), Capture: true})
+	}
+	quiet := movegen.NewMoveGenerator(g.Copy())
+	for _, mv := range movegen.ConsumeAllQuiet(quiet) {
+		output = append(output, legalMove{Move: mv.Coord()})
+	}
+	return output
+}
+
+func coords(moves []game.Move) []string {
+	output := make([]string, len(moves))
+	for i := range moves {
+		output[i] = moves[i].Coord()
+	}
+	return output
+}
+
+type engineInfo struct {
+	Name        string `json:"name"`
+	Spec        string `json:"spec"`
+	Description string `json:"description,omitempty"`
+}
+
+type engineListing struct {
+	Named       []engineInfo `json:"named"`
+	Searches    []engineInfo `json:"searches"`
+	Evaluations []engineInfo `json:"evaluations"`
+}
+
+func engineList() engineListing {
+	output := engineListing{}
+	for _, n := range engines.Named {
+		output.Named = append(output.Named, engineInfo{Name: n.Name, Spec: n.Spec})
+	}
+	for _, s := range engines.Searches {
+		params := []string{}
+		for _, p := range s.Params {
+			params = append(params, p.Name+"="+p.Default)
+		}
+		spec := s.Name
+		if len(params) > 0 {
+			spec += "(" + strings.Join(params, ",") + ")"
+		}
+		output.Searches = append(output.Searches, engineInfo{Name: s.Name, Spec: spec, Description: s.Description})
+	}
+	for _, e := range engines.Evaluators {
+		output.Evaluations = append(output.Evaluations, engineInfo{Name: e.Name, Spec: e.Name, Description: e.Description})
+	}
+	return output
+}
@@ -0,0 +1,296 @@
+"use strict";
+
+const glyphs = {
+  K: "♚", Q: "♛", R: "♜", B: "♝", N: "♞", P: "♟",
+};
+
+const $ = (id) => document.getElementById(id);
+
+let game = null;   // the game as returned by the API
+let legal = [];    // legal moves of the current position
+let selected = null;
+let busy = false;  // waiting for the engine
+
+async function api(method, path, body) {
+  const opts = { method, headers: {} };
+  if (body !== undefined) {
+    opts.headers["Content-Type"] = "application/json";
+    opts.body = JSON.stringify(body);
+  }
+  const res = await fetch("/api" + path, opts);
+  const data = await res.json();
+  if (!res.ok) {
+    throw new Error(data.error || res.statusText);
+  }
+  return data;
+}
+
+function showError(err) {
+  $("status").innerHTML = "";
+  const span = document.createElement("span");
+  span.className = "error";
+  span.textContent = err.message;
+  $("status").appendChild(span);
+}
+
+// parsePlacement returns the 64 squares of the FEN, rank 8 first
+function parsePlacement(fen) {
+  const squares = [];
+  for (const ch of fen.split(" ")[0]) {
+    if (ch === "/") continue;
+    if (ch >= "1" && ch <= "8") {
+      for (let i = 0; i < Number(ch); i++) squares.push(null);
+    } else {
+      squares.push(ch);
+    }
+  }
+  return squares;
+}
+
+function squareName(index) {
+  return "abcdefgh"[index % 8] + (8 - Math.floor(index / 8));
+}
+
+function playerSides() {
+  const side = $("side").value;
+  return side === "both" ? ["white", "black"] : [side];
+}
+
+function playersTurn() {
+  return game && !game.over && playerSides().includes(game.turn);
+}
+
+function flipped() {
+  return $("side").value === "black";
+}
+
+function render() {
+  const board = $("board");
+  board.innerHTML = "";
+  const squares = parsePlacement(game.fen);
+  const last = game.moves.length > 0 ? game.moves[game.moves.length - 1] : "";
+  const targets = {};
+  if (selected) {
+    for (const mv of legal) {
+      if (mv.move.startsWith(selected)) targets[mv.move.slice(2, 4)] = mv.capture ? "capture" : "quiet";
+    }
+  } else if ($("attacks").checked) {
+    for (const mv of legal) {
+      if (mv.capture) targets[mv.move.slice(2, 4)] = "capture";
+    }
+  }
+  for (let i = 0; i < 64; i++) {
+    const index = flipped() ? 63 - i : i;
+    const name = squareName(index);
+    const row = Math.floor(index / 8);
+    const col = index % 8;
+    const sq = document.createElement("div");
+    sq.className = "square " + ((row + col) % 2 === 0 ? "light" : "dark");
+    sq.dataset.square = name;
+    if (name === selected) sq.classList.add("selected");
+    if (last.slice(0, 2) === name || last.slice(2, 4) === name) sq.classList.add("last");
+    if (targets[name]) sq.classList.add(targets[name]);
+    if (i % 8 === 0 || i >= 56) {
+      const coord = document.createElement("span");
+      coord.className = "coord";
+      coord.textContent = (i >= 56 ? name[0] : "") + (i % 8 === 0 ? name[1] : "");
+      sq.appendChild(coord);
+    }
+    const p = squares[index];
+    if (p) {
+      const piece = document.createElement("span");
+      const color = p === p.toUpperCase() ? "white" : "black";
+      piece.className = "piece " + color;
+      piece.textContent = glyphs[p.toUpperCase()];
+      if (playersTurn() && color === game.turn && !busy) {
+        piece.draggable = true;
+        piece.addEventListener("dragstart", (e) => {
+          selected = name;
+          e.dataTransfer.setData("text/plain", name);
+          setTimeout(render, 0);
+        });
+      }
+      sq.appendChild(piece);
+    }
+    sq.addEventListener("dragover", (e) => e.preventDefault());
+    sq.addEventListener("drop", (e) => {
+      e.preventDefault();
+      const from = e.dataTransfer.getData("text/plain");
+      selected = null;
+      tryMove(from, name);
+    });
+    sq.addEventListener("click", () => clickSquare(name, p));
+    board.appendChild(sq);
+  }
+  renderMoves();
+  renderStatus();
+}
+
+function clickSquare(name, piece) {
+  if (!playersTurn() || busy) return;
+  if (selected && selected !== name && legal.some((mv) => mv.move === selected + name)) {
+    const from = selected;
+    selected = null;
+    tryMove(from, name);
+    return;
+  }
+  const own = piece && (piece === piece.toUpperCase()) === (game.turn === "white");
+  selected = own && selected !== name ? name : null;
+  render();
+}
+
+function renderMoves() {
+  const list = $("moves");
+  list.innerHTML = "";
+  for (let i = 0; i < game.moves.length; i += 2) {
+    const li = document.createElement("li");
+    li.textContent = game.moves[i] + (i + 1 < game.moves.length ? "  " + game.moves[i + 1] : "");
+    list.appendChild(li);
+  }
+  list.scrollTop = list.scrollHeight;
+}
+
+function renderStatus() {
+  const status = $("status");
+  if (game.over) {
+    status.textContent = { "1-0": "White wins", "0-1": "Black wins", "1/2-1/2": "Draw" }[game.result] + ": " + game.reason;
+  } else if (busy) {
+    status.textContent = "thinking...";
+  } else {
+    status.textContent = game.turn + " to move";
+  }
+}
+
+async function update(g) {
+  game = g;
+  legal = game.over ? [] : await api("GET", "/games/" + game.id + "/legal");
+  render();
+}
+
+async function tryMove(from, to) {
+  try {
+    await update(await api("POST", "/games/" + game.id + "/moves", { move: from + to }));
+  } catch (err) {
+    render();
+    showError(err);
+    return;
+  }
+  reply();
+}
+
+// reply lets the engine play while it is not the player's turn
+async function reply() {
+  while (game && !game.over && !playersTurn()) {
+    const ok = await engineMove();
+    if (!ok) return;
+  }
+}
+
+function engineSpec() {
+  return $("spec").value.trim() || $("engine").value;
+}
+
+async function engineMove() {
+  busy = true;
+  render();
+  try {
+    const res = await api("POST", "/games/" + game.id + "/engine", {
+      engine: engineSpec(),
+      depth: Number($("depth").value) || 0,
+      movetime: Number($("movetime").value) || 0,
+    });
+    $("analysis").textContent = res.engine + ": " + res.move + " score " + res.score +
+      " depth " + res.depth + " nodes " + res.nodes + " " + res.time_ms + "ms pv " + res.pv.join(" ");
+    busy = false;
+    await update(res.game);
+    return true;
+  } catch (err) {
+    busy = false;
+    render();
+    showError(err);
+    return false;
+  }
+}
+
+async function newGame(body) {
+  selected = null;
+  $("analysis").textContent = "";
+  try {
+    await update(await api("POST", "/games", body));
+  } catch (err) {
+    showError(err);
+    return;
+  }
+  reply();
+}
+
+// undo goes back to the last position where the player was to move
+async function undo() {
+  if (!game || busy || game.moves.length === 0) return;
+  let plies = 1;
+  if (playerSides().length === 1 && game.moves.length >= 2 && (playersTurn() || game.over)) {
+    plies = 2;
+  }
+  selected = null;
+  try {
+    await update(await api("POST", "/games/" + game.id + "/undo", { plies }));
+  } catch (err) {
+    showError(err);
+  }
+}
+
+async function importGame() {
+  const text = $("io").value.trim();
+  if (text.startsWith("{")) {
+    let rec;
+    try {
+      rec = JSON.parse(text);
+    } catch (err) {
+      showError(err);
+      return;
+    }
+    await newGame({ fen: rec.start, moves: rec.moves || [] });
+  } else {
+    await newGame({ fen: text });
+  }
+}
+
+async function exportRecord() {
+  if (!game) return;
+  const rec = await api("GET", "/games/" + game.id + "/record");
+  $("io").value = JSON.stringify(rec);
+}
+
+async function loadEngines() {
+  const list = await api("GET", "/engines");
+  const select = $("engine");
+  const named = document.createElement("optgroup");
+  named.label = "named";
+  for (const e of list.named) {
+    const opt = document.createElement("option");
+    opt.value = e.name;
+    opt.textContent = e.name + " = " + e.spec;
+    named.appendChild(opt);
+  }
+  select.appendChild(named);
+  select.value = "typeb_psqt";
+  const help = list.searches.map((s) => s.spec + ": " + s.description).join("\n") +
+    "\nevaluations: " + list.evaluations.map((e) => e.name).join(", ");
+  $("engines-help").innerText = help;
+}
+
+$("new").addEventListener("click", () => newGame({ layout: $("layout").value }));
+$("undo").addEventListener("click", undo);
+$("enginemove").addEventListener("click", async () => {
+  if (game && !game.over && !busy) {
+    await engineMove();
+    reply();
+  }
+});
+$("side").addEventListener("change", () => { render(); reply(); });
+$("attacks").addEventListener("change", render);
+$("import").addEventListener("click", importGame);
+$("export-fen").addEventListener("click", () => { if (game) $("io").value = game.fen; });
+$("export-record").addEventListener("click", exportRecord);
+
+loadEngines().then(() => newGame({ layout: "standard" }));
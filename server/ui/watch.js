@@ -0,0 +1,102 @@
+"use strict";
+
+const glyphs = {
+  K: "♚", Q: "♛", R: "♜", B: "♝", N: "♞", P: "♟",
+};
+
+// how many finished games are listed
+const keepFinished = 100;
+
+const $ = (id) => document.getElementById(id);
+
+const running = new Map(); // game id -> last event and its element
+const matches = new Map(); // match name -> tally
+
+function miniBoard(fen, last) {
+  const board = document.createElement("div");
+  board.className = "mini";
+  let index = 0;
+  for (const ch of fen.split(" ")[0]) {
+    if (ch === "/") continue;
+    const n = ch >= "1" && ch <= "8" ? Number(ch) : 1;
+    for (let i = 0; i < n; i++, index++) {
+      const sq = document.createElement("div");
+      const row = Math.floor(index / 8);
+      const col = index % 8;
+      const name = "abcdefgh"[col] + (8 - row);
+      sq.className = "square " + ((row + col) % 2 === 0 ? "light" : "dark");
+      if (last && (last.slice(0, 2) === name || last.slice(2, 4) === name)) sq.classList.add("last");
+      if (n === 1 && !(ch >= "1" && ch <= "8")) {
+        const piece = document.createElement("span");
+        piece.className = "piece " + (ch === ch.toUpperCase() ? "white" : "black");
+        piece.textContent = glyphs[ch.toUpperCase()];
+        sq.appendChild(piece);
+      }
+      board.appendChild(sq);
+    }
+  }
+  return board;
+}
+
+function renderGame(e) {
+  let entry = running.get(e.game);
+  if (!entry) {
+    const el = document.createElement("div");
+    el.className = "game";
+    $("running").appendChild(el);
+    entry = { el };
+    running.set(e.game, entry);
+  }
+  entry.event = e;
+  const el = entry.el;
+  el.innerHTML = "";
+  const title = document.createElement("div");
+  title.textContent = e.game + ": " + e.white + " vs " + e.black;
+  const info = document.createElement("div");
+  info.className = "info";
+  info.textContent = e.kind === "move" ? "ply " + e.ply + " " + e.move + " score " + e.score : "started";
+  el.append(title, miniBoard(e.fen, e.move), info);
+}
+
+function finish(e) {
+  const entry = running.get(e.game);
+  if (entry) {
+    entry.el.remove();
+    running.delete(e.game);
+  }
+  const li = document.createElement("li");
+  li.textContent = e.game + ": " + e.white + " vs " + e.black + " " + e.result + " (" + e.reason + ")";
+  const list = $("finished");
+  list.prepend(li);
+  while (list.children.length > keepFinished) list.lastChild.remove();
+
+  const name = e.match || "other games";
+  const tally = matches.get(name) || { games: 0, white: 0, draws: 0, black: 0 };
+  tally.games++;
+  if (e.result === "1-0") tally.white++;
+  else if (e.result === "0-1") tally.black++;
+  else tally.draws++;
+  matches.set(name, tally);
+  renderMatches();
+}
+
+function renderMatches() {
+  const body = $("matches").querySelector("tbody");
+  body.innerHTML = "";
+  for (const [name, t] of matches) {
+    const tr = document.createElement("tr");
+    for (const v of [name, t.games, t.white, t.draws, t.black]) {
+      const td = document.createElement("td");
+      td.textContent = v;
+      tr.appendChild(td);
+    }
+    body.appendChild(tr);
+  }
+}
+
+const source = new EventSource("/api/events");
+source.onopen = () => { $("connection").textContent = "connected, waiting for games"; };
+source.onerror = () => { $("connection").textContent = "disconnected, retrying..."; };
+source.addEventListener("start", (msg) => renderGame(JSON.parse(msg.data)));
+source.addEventListener("move", (msg) => renderGame(JSON.parse(msg.data)));
+source.addEventListener("end", (msg) => finish(JSON.parse(msg.data)));
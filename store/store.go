@@ -0,0 +1,186 @@
+// Package store keeps the positions saved in the REPL, with the moves
+// that led to them, in a JSON file so that they outlive the process
+package store
+
+import (
+	"chess/game"
+	"chess/game/record"
+	rs "chess/game/result"
+
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type Entry struct {
+	record.Game
+	Saved time.Time `json:"saved"`
+}
+
+// Store maps labels to saved games, every change is written to Path.
+// If Path is empty nothing is written
+type Store struct {
+	Path    string
+	entries map[string]*Entry
+}
+
+// DefaultPath is where the store is kept if no other is given
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "saved.json"
+	}
+	return filepath.Join(dir, "chess", "saved.json")
+}
+
+// Open loads the store at path, a missing file is an empty store
+func Open(path string) (*Store, error) {
+	output := &Store{Path: path, entries: map[string]*Entry{}}
+	if path == "" {
+		return output, nil
+	}
+	entries, err := readFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return output, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	output.entries = entries
+	return output, nil
+}
+
+func readFile(path string) (map[string]*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]*Entry{}
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	return entries, nil
+}
+
+// writeFile replaces the file at once, so that a crash
+// doesn't leave it half written
+func writeFile(path string, entries map[string]*Entry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (this *Store) flush() error {
+	if this.Path == "" {
+		return nil
+	}
+	return writeFile(this.Path, this.entries)
+}
+
+// Save keeps the game under the label, replacing what was there
+func (this *Store) Save(label string, g *game.GameState) error {
+	start := g.Copy()
+	for start.Moves.Len() > 0 {
+		start.UnMove()
+	}
+	this.entries[label] = &Entry{
+		Game:  *record.New("", "", start, g),
+		Saved: time.Now(),
+	}
+	return this.flush()
+}
+
+// Get replays the game saved under the label
+func (this *Store) Get(label string) (*game.GameState, error) {
+	e, ok := this.entries[label]
+	if !ok {
+		return nil, errors.New(label + " doesn't exist")
+	}
+	g, err := e.Replay()
+	if err != nil {
+		return nil, errors.New(label + ": " + err.Error())
+	}
+	// games that ended off the board, like on time
+	if !g.IsOver && e.Result != rs.InvalidResult {
+		g.End(e.Result, e.Reason)
+	}
+	return g, nil
+}
+
+func (this *Store) Entry(label string) (*Entry, bool) {
+	e, ok := this.entries[label]
+	return e, ok
+}
+
+func (this *Store) Delete(label string) error {
+	_, ok := this.entries[label]
+	if !ok {
+		return errors.New(label + " doesn't exist")
+	}
+	delete(this.entries, label)
+	return this.flush()
+}
+
+// Labels are sorted alphabetically
+func (this *Store) Labels() []string {
+	output := make([]string, 0, len(this.entries))
+	for label := range this.entries {
+		output = append(output, label)
+	}
+	sort.Strings(output)
+	return output
+}
+
+// Export writes the entries with the labels to a file in the format of
+// the store, all entries if no label is given
+func (this *Store) Export(path string, labels ...string) error {
+	if len(labels) == 0 {
+		labels = this.Labels()
+	}
+	entries := map[string]*Entry{}
+	for _, label := range labels {
+		e, ok := this.entries[label]
+		if !ok {
+			return errors.New(label + " doesn't exist")
+		}
+		entries[label] = e
+	}
+	return writeFile(path, entries)
+}
+
+// Import adds the entries of a file written by Export, replacing
+// those with the same labels. Returns the labels imported
+func (this *Store) Import(path string) ([]string, error) {
+	entries, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	labels := []string{}
+	for label, e := range entries {
+		_, err = e.Replay()
+		if err != nil {
+			return nil, errors.New(label + ": " + err.Error())
+		}
+		labels = append(labels, label)
+	}
+	for label, e := range entries {
+		this.entries[label] = e
+	}
+	sort.Strings(labels)
+	return labels, this.flush()
+}
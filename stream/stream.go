@@ -0,0 +1,116 @@
+// live events of running games, served as Server-Sent Events
+package stream
+
+import (
+	rs "chess/game/result"
+
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	Start = "start"
+	Move  = "move"
+	End   = "end"
+)
+
+// Event is something that happened in a game, each event carries
+// the position so that clients may join at any time
+type Event struct {
+	Kind  string `json:"kind"`
+	Game  string `json:"game"`
+	Match string `json:"match,omitempty"`
+	White string `json:"white"`
+	Black string `json:"black"`
+	FEN   string `json:"fen"`
+
+	// only on moves, the score is positive when white is better
+	Ply   int    `json:"ply,omitempty"`
+	Move  string `json:"move,omitempty"`
+	Score int    `json:"score"`
+
+	// only on the end of the game
+	Result rs.Result `json:"result"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// clients that can't keep up lose events instead of
+// slowing down the games
+const buffer = 1024
+
+// Broker sends every published event to every subscriber,
+// a nil broker ignores everything
+type Broker struct {
+	clients map[chan Event]struct{}
+	sync.Mutex
+}
+
+func NewBroker() *Broker {
+	return &Broker{clients: map[chan Event]struct{}{}}
+}
+
+func (this *Broker) Publish(e Event) {
+	if this == nil {
+		return
+	}
+	this.Lock()
+	defer this.Unlock()
+	for c := range this.clients {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns the events and a function to stop receiving them
+func (this *Broker) Subscribe() (<-chan Event, func()) {
+	c := make(chan Event, buffer)
+	this.Lock()
+	this.clients[c] = struct{}{}
+	this.Unlock()
+	return c, func() {
+		this.Lock()
+		delete(this.clients, c)
+		this.Unlock()
+	}
+}
+
+// ServeHTTP streams the events until the client leaves
+func (this *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	events, unsubscribe := this.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	// comments keep proxies from closing idle connections
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case e := <-events:
+			data, err := json.Marshal(e)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Fprintf(w, "event: %v\ndata: %s\n\n", e.Kind, data)
+		}
+		flusher.Flush()
+	}
+}
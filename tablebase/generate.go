@@ -0,0 +1,417 @@
+package tablebase
+
+import (
+	"chess/game"
+	pc "chess/game/piece"
+
+	"fmt"
+	"time"
+)
+
+/*
+Generation is done by retrograde analysis, starting from the
+positions where the king can be captured and walking the moves
+backwards, level by level:
+
+	a position is won in d plies if some move leads to
+	a position lost in d-1 plies for the opponent
+
+	a position is lost in d plies if every move leads to
+	a position won by the opponent, the longest being d-1
+
+Moves that capture a piece or promote a pawn leave the table,
+their values are looked up on the smaller tables, which are
+generated first. Anything never reached is a draw.
+
+The 50 move rule is ignored.
+*/
+
+// Generate builds the table for the material, and every table it depends on,
+// adding them to the set. Tables already in the set are not generated again
+func (this *Set) Generate(m Material, log func(string)) *Table {
+	if t := this.find(m); t != nil {
+		return t
+	}
+	for _, dep := range successors(m) {
+		this.Generate(dep, log)
+	}
+	start := time.Now()
+	t := generate(this, m)
+	this.Add(t)
+	if log != nil {
+		log(fmt.Sprintf("%v: %v, took %v", m, t.Stats(), time.Since(start)))
+	}
+	return t
+}
+
+// find returns the table for the material or its color flipped twin
+func (this *Set) find(m Material) *Table {
+	if t, ok := this.tables[m.String()]; ok {
+		return t
+	}
+	p := placement{N: len(m)}
+	copy(p.Pieces[:], m)
+	flip := p.flipped()
+	return this.tables[flip.material().String()]
+}
+
+// materials reachable by a capture or a promotion
+func successors(m Material) []Material {
+	output := []Material{}
+	for i, p := range m {
+		if p.IsKingLike() {
+			continue
+		}
+		smaller := make(Material, 0, len(m)-1)
+		smaller = append(smaller, m[:i]...)
+		smaller = append(smaller, m[i+1:]...)
+		output = append(output, smaller)
+		if p.IsPawnLike() {
+			prom := make(Material, len(m))
+			copy(prom, m)
+			prom[i] = promoted(p)
+			prom.sort()
+			output = append(output, prom)
+		}
+	}
+	return output
+}
+
+type generator struct {
+	set      *Set
+	material Material
+	n        int
+
+	values   []Value
+	counters []uint8 // moves inside the table not yet known to lose
+	safe     []bool  // a move out of the table avoids losing
+	exitLoss []uint8 // longest loss through moves out of the table
+	queued   []uint8 // level at which a win is already queued
+
+	wins   [][]uint32
+	losses [][]uint32
+}
+
+func generate(set *Set, m Material) *Table {
+	size := m.Positions()
+	gen := &generator{
+		set:      set,
+		material: m,
+		n:        len(m),
+		values:   make([]Value, size),
+		counters: make([]uint8, size),
+		safe:     make([]bool, size),
+		exitLoss: make([]uint8, size),
+		queued:   make([]uint8, size),
+		wins:     make([][]uint32, maxPlies+2),
+		losses:   make([][]uint32, maxPlies+2),
+	}
+	for idx := 0; idx < size; idx++ {
+		p, ok := gen.decode(idx)
+		if ok {
+			gen.initialize(idx, &p)
+		}
+	}
+	for level := 1; level <= maxPlies; level++ {
+		for _, idx := range gen.losses[level] {
+			gen.resolve(int(idx), loss(level))
+		}
+		gen.losses[level] = nil
+		for _, idx := range gen.wins[level] {
+			gen.resolve(int(idx), win(level))
+		}
+		gen.wins[level] = nil
+	}
+	return &Table{Material: m, Data: gen.values}
+}
+
+func (this *generator) decode(idx int) (placement, bool) {
+	p := placement{N: this.n, BlackTurn: idx&1 == 1}
+	rest := idx >> 1
+	for i := this.n - 1; i >= 0; i-- {
+		p.Pieces[i] = this.material[i]
+		p.Squares[i] = rest & 63
+		rest >>= 6
+	}
+	for i := 0; i < p.N; i++ {
+		for j := i + 1; j < p.N; j++ {
+			if p.Squares[i] == p.Squares[j] {
+				return p, false
+			}
+		}
+		row := p.Squares[i] / 8
+		if p.Pieces[i].IsPawnLike() && (row == 0 || row == 7) {
+			return p, false
+		}
+	}
+	return p, true
+}
+
+// initialize looks at the moves out of the position: captures
+// of the king are immediate wins, other captures and promotions
+// are looked up, while the rest are counted
+func (this *generator) initialize(idx int, p *placement) {
+	exitWin := 0
+	moves := 0
+	forEachMove(p, func(piece, to, captured int) {
+		moves++
+		if exitWin == 1 {
+			return
+		}
+		if captured >= 0 && p.Pieces[captured].IsKingLike() {
+			exitWin = 1
+			return
+		}
+		isPromotion := p.Pieces[piece].IsPawnLike() && (to/8 == 0 || to/8 == 7)
+		if captured < 0 && !isPromotion {
+			this.counters[idx]++
+			return
+		}
+		next := *p
+		next.Squares[piece] = to
+		if isPromotion {
+			next.Pieces[piece] = promoted(next.Pieces[piece])
+		}
+		if captured >= 0 {
+			next.remove(captured)
+		}
+		next.BlackTurn = !next.BlackTurn
+		v, ok := this.set.lookup(next)
+		if !ok {
+			panic("missing table for " + next.material().String())
+		}
+		switch {
+		case v.IsLoss():
+			if exitWin == 0 || v.Plies()+1 < exitWin {
+				exitWin = v.Plies() + 1
+			}
+		case v.IsWin():
+			if uint8(v.Plies()+1) > this.exitLoss[idx] {
+				this.exitLoss[idx] = uint8(v.Plies() + 1)
+			}
+		default:
+			this.safe[idx] = true
+		}
+	})
+	if exitWin > 0 {
+		this.safe[idx] = true
+		this.queueWin(idx, exitWin)
+		return
+	}
+	if moves > 0 && this.counters[idx] == 0 && !this.safe[idx] {
+		this.queueLoss(idx, int(this.exitLoss[idx]))
+	}
+}
+
+func (this *generator) queueWin(idx, level int) {
+	if level > maxPlies {
+		panic("position too long to win")
+	}
+	if this.queued[idx] != 0 && int(this.queued[idx]) <= level {
+		return
+	}
+	this.queued[idx] = uint8(level)
+	this.wins[level] = append(this.wins[level], uint32(idx))
+}
+
+func (this *generator) queueLoss(idx, level int) {
+	if level > maxPlies {
+		panic("position too long to lose")
+	}
+	this.losses[level] = append(this.losses[level], uint32(idx))
+}
+
+// resolve sets the value of the position and propagates it
+// to the positions that lead to it
+func (this *generator) resolve(idx int, v Value) {
+	if this.values[idx] != Draw {
+		return
+	}
+	this.values[idx] = v
+	p, _ := this.decode(idx)
+	level := v.Plies()
+	forEachUnmove(&p, func(prev placement) {
+		q := prev.index()
+		if this.values[q] != Draw {
+			return
+		}
+		if v.IsLoss() {
+			this.queueWin(q, level+1)
+			return
+		}
+		this.counters[q]--
+		if this.counters[q] == 0 && !this.safe[q] {
+			longest := level + 1
+			if int(this.exitLoss[q]) > longest {
+				longest = int(this.exitLoss[q])
+			}
+			this.queueLoss(q, longest)
+		}
+	})
+}
+
+// forEachMove calls fn for every move of the side to move,
+// captured is the index of the captured piece or -1
+func forEachMove(p *placement, fn func(piece, to, captured int)) {
+	for i := 0; i < p.N; i++ {
+		piece := p.Pieces[i]
+		if piece.IsBlack() != p.BlackTurn {
+			continue
+		}
+		from := p.Squares[i]
+		switch piece {
+		case pc.WhiteKing, pc.BlackKing:
+			simpleMoves(p, i, from, game.KingOffsets, fn)
+		case pc.WhiteKnight, pc.BlackKnight:
+			simpleMoves(p, i, from, game.HorsieOffsets, fn)
+		case pc.WhiteQueen, pc.BlackQueen:
+			slideMoves(p, i, from, game.QueenOffsets, fn)
+		case pc.WhiteRook, pc.BlackRook:
+			slideMoves(p, i, from, game.RookOffsets, fn)
+		case pc.WhiteBishop, pc.BlackBishop:
+			slideMoves(p, i, from, game.BishopOffsets, fn)
+		case pc.WhitePawn:
+			pawnMoves(p, i, from, -1, fn)
+		case pc.BlackPawn:
+			pawnMoves(p, i, from, 1, fn)
+		}
+	}
+}
+
+func simpleMoves(p *placement, i, from int, offsets []game.Point, fn func(piece, to, captured int)) {
+	for _, offset := range offsets {
+		row := from/8 + offset.Row
+		col := from%8 + offset.Column
+		if row < 0 || row > 7 || col < 0 || col > 7 {
+			continue
+		}
+		to := row*8 + col
+		other := p.at(to)
+		if other < 0 {
+			fn(i, to, -1)
+		} else if p.Pieces[other].IsBlack() != p.BlackTurn {
+			fn(i, to, other)
+		}
+	}
+}
+
+func slideMoves(p *placement, i, from int, offsets []game.Point, fn func(piece, to, captured int)) {
+	for _, offset := range offsets {
+		row := from / 8
+		col := from % 8
+		for {
+			row += offset.Row
+			col += offset.Column
+			if row < 0 || row > 7 || col < 0 || col > 7 {
+				break
+			}
+			to := row*8 + col
+			other := p.at(to)
+			if other < 0 {
+				fn(i, to, -1)
+				continue
+			}
+			if p.Pieces[other].IsBlack() != p.BlackTurn {
+				fn(i, to, other)
+			}
+			break
+		}
+	}
+}
+
+func pawnMoves(p *placement, i, from, forward int, fn func(piece, to, captured int)) {
+	row := from/8 + forward
+	col := from % 8
+	if row < 0 || row > 7 {
+		return
+	}
+	if p.at(row*8+col) < 0 {
+		fn(i, row*8+col, -1)
+	}
+	for _, c := range []int{col - 1, col + 1} {
+		if c < 0 || c > 7 {
+			continue
+		}
+		other := p.at(row*8 + c)
+		if other >= 0 && p.Pieces[other].IsBlack() != p.BlackTurn {
+			fn(i, row*8+c, other)
+		}
+	}
+}
+
+// forEachUnmove calls fn for every position that leads to p by a
+// move that stays in the table (no captures, no promotions)
+func forEachUnmove(p *placement, fn func(prev placement)) {
+	for i := 0; i < p.N; i++ {
+		piece := p.Pieces[i]
+		if piece.IsBlack() == p.BlackTurn {
+			continue // the side to move didn't move last
+		}
+		to := p.Squares[i]
+		back := func(from int) {
+			prev := *p
+			prev.Squares[i] = from
+			prev.BlackTurn = !prev.BlackTurn
+			fn(prev)
+		}
+		switch piece {
+		case pc.WhiteKing, pc.BlackKing:
+			simpleUnmoves(p, to, game.KingOffsets, back)
+		case pc.WhiteKnight, pc.BlackKnight:
+			simpleUnmoves(p, to, game.HorsieOffsets, back)
+		case pc.WhiteQueen, pc.BlackQueen:
+			slideUnmoves(p, to, game.QueenOffsets, back)
+		case pc.WhiteRook, pc.BlackRook:
+			slideUnmoves(p, to, game.RookOffsets, back)
+		case pc.WhiteBishop, pc.BlackBishop:
+			slideUnmoves(p, to, game.BishopOffsets, back)
+		case pc.WhitePawn:
+			pawnUnmove(p, to, 1, back)
+		case pc.BlackPawn:
+			pawnUnmove(p, to, -1, back)
+		}
+	}
+}
+
+func simpleUnmoves(p *placement, to int, offsets []game.Point, back func(from int)) {
+	for _, offset := range offsets {
+		row := to/8 + offset.Row
+		col := to%8 + offset.Column
+		if row < 0 || row > 7 || col < 0 || col > 7 {
+			continue
+		}
+		if p.at(row*8+col) < 0 {
+			back(row*8 + col)
+		}
+	}
+}
+
+func slideUnmoves(p *placement, to int, offsets []game.Point, back func(from int)) {
+	for _, offset := range offsets {
+		row := to / 8
+		col := to % 8
+		for {
+			row += offset.Row
+			col += offset.Column
+			if row < 0 || row > 7 || col < 0 || col > 7 {
+				break
+			}
+			if p.at(row*8+col) >= 0 {
+				break
+			}
+			back(row*8 + col)
+		}
+	}
+}
+
+// pawns come from the square behind them, and never from the back rank
+func pawnUnmove(p *placement, to, backward int, back func(from int)) {
+	row := to/8 + backward
+	if row < 1 || row > 6 {
+		return
+	}
+	from := row*8 + to%8
+	if p.at(from) < 0 {
+		back(from)
+	}
+}
@@ -0,0 +1,194 @@
+package tablebase
+
+import (
+	pc "chess/game/piece"
+
+	"errors"
+	"sort"
+	"strings"
+)
+
+// MaxPieces is the largest number of pieces (kings included)
+// a table may have, 64^5 positions would not fit in memory
+const MaxPieces = 4
+
+// Material lists the pieces of a table: white pieces first,
+// kings first, then from the most to the least valuable
+type Material []pc.Piece
+
+// ParseMaterial reads materials written like KQvK or KNvKP,
+// white pieces go on the left
+func ParseMaterial(s string) (Material, error) {
+	sides := strings.Split(s, "v")
+	if len(sides) != 2 {
+		return nil, errors.New("invalid material: " + s)
+	}
+	output := Material{}
+	for i, side := range sides {
+		if !strings.HasPrefix(side, "K") || strings.Count(side, "K") != 1 {
+			return nil, errors.New("each side needs exactly one king: " + s)
+		}
+		for _, r := range side {
+			p, ok := pieceFromRune(r, i == 1)
+			if !ok {
+				return nil, errors.New("invalid piece '" + string(r) + "' in " + s)
+			}
+			output = append(output, p)
+		}
+	}
+	if len(output) > MaxPieces {
+		return nil, errors.New("too many pieces: " + s)
+	}
+	output.sort()
+	return output, nil
+}
+
+func pieceFromRune(r rune, isBlack bool) (pc.Piece, bool) {
+	pawn, knight, bishop, rook, queen, king := pc.WhitePieces()
+	if isBlack {
+		pawn, knight, bishop, rook, queen, king = pc.BlackPieces()
+	}
+	switch r {
+	case 'K':
+		return king, true
+	case 'Q':
+		return queen, true
+	case 'R':
+		return rook, true
+	case 'B':
+		return bishop, true
+	case 'N':
+		return knight, true
+	case 'P':
+		return pawn, true
+	}
+	return pc.InvalidPiece, false
+}
+
+func (this Material) String() string {
+	output := ""
+	for i, p := range this {
+		if i > 0 && p.IsBlack() && this[i-1].IsWhite() {
+			output += "v"
+		}
+		output += strings.ToUpper(p.String())
+	}
+	return output
+}
+
+func (this Material) sort() {
+	sort.SliceStable(this, func(i, j int) bool {
+		return order(this[i]) < order(this[j])
+	})
+}
+
+// Positions is the size of the table, one entry for
+// every placement of the pieces and side to move
+func (this Material) Positions() int {
+	output := 2
+	for range this {
+		output *= 64
+	}
+	return output
+}
+
+func order(p pc.Piece) int {
+	output := 0
+	if p.IsBlack() {
+		output = 10
+	}
+	switch p {
+	case pc.WhiteKing, pc.BlackKing:
+		return output
+	case pc.WhiteQueen, pc.BlackQueen:
+		return output + 1
+	case pc.WhiteRook, pc.BlackRook:
+		return output + 2
+	case pc.WhiteBishop, pc.BlackBishop:
+		return output + 3
+	case pc.WhiteKnight, pc.BlackKnight:
+		return output + 4
+	}
+	return output + 5
+}
+
+func swapColor(p pc.Piece) pc.Piece {
+	if p.IsWhite() {
+		return p + (pc.BlackPawn - pc.WhitePawn)
+	}
+	return p - (pc.BlackPawn - pc.WhitePawn)
+}
+
+func promoted(p pc.Piece) pc.Piece {
+	if p == pc.BlackPawn {
+		return pc.BlackQueen
+	}
+	return pc.WhiteQueen
+}
+
+// placement is a set of pieces on the board, with
+// the side to move, it need not be in canonical order
+type placement struct {
+	Pieces    [MaxPieces]pc.Piece
+	Squares   [MaxPieces]int
+	N         int
+	BlackTurn bool
+}
+
+func (this *placement) material() Material {
+	output := make(Material, this.N)
+	copy(output, this.Pieces[:this.N])
+	return output
+}
+
+// canonical sorts the pieces as in Material
+func (this *placement) canonical() {
+	for i := 1; i < this.N; i++ {
+		for j := i; j > 0 && order(this.Pieces[j]) < order(this.Pieces[j-1]); j-- {
+			this.Pieces[j], this.Pieces[j-1] = this.Pieces[j-1], this.Pieces[j]
+			this.Squares[j], this.Squares[j-1] = this.Squares[j-1], this.Squares[j]
+		}
+	}
+}
+
+// flipped swaps the colors and mirrors the board vertically,
+// the value of the position doesn't change
+func (this *placement) flipped() placement {
+	output := placement{N: this.N, BlackTurn: !this.BlackTurn}
+	for i := 0; i < this.N; i++ {
+		output.Pieces[i] = swapColor(this.Pieces[i])
+		row := this.Squares[i] / 8
+		col := this.Squares[i] % 8
+		output.Squares[i] = (7-row)*8 + col
+	}
+	output.canonical()
+	return output
+}
+
+// index assumes the placement is in canonical order
+func (this *placement) index() int {
+	output := 0
+	for i := 0; i < this.N; i++ {
+		output = output*64 + this.Squares[i]
+	}
+	output *= 2
+	if this.BlackTurn {
+		output++
+	}
+	return output
+}
+
+func (this *placement) remove(i int) {
+	copy(this.Pieces[i:this.N], this.Pieces[i+1:this.N])
+	copy(this.Squares[i:this.N], this.Squares[i+1:this.N])
+	this.N--
+}
+
+func (this *placement) at(sq int) int {
+	for i := 0; i < this.N; i++ {
+		if this.Squares[i] == sq {
+			return i
+		}
+	}
+	return -1
+}
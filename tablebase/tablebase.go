@@ -0,0 +1,386 @@
+// endgame tablebases with the distance to king capture
+package tablebase
+
+import (
+	"chess/game"
+	rs "chess/game/result"
+	ifaces "chess/interfaces"
+	movegen "chess/movegen/segregated"
+
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Value of a position for the side to move: a draw, or a win
+// or loss with the number of plies until the king is captured
+type Value byte
+
+const Draw Value = 0
+
+const lossBit = 128
+const maxPlies = lossBit - 1
+
+func win(plies int) Value {
+	return Value(plies)
+}
+
+func loss(plies int) Value {
+	return Value(plies | lossBit)
+}
+
+func (this Value) IsWin() bool {
+	return this != Draw && this&lossBit == 0
+}
+
+func (this Value) IsLoss() bool {
+	return this&lossBit != 0
+}
+
+func (this Value) Plies() int {
+	return int(this &^ lossBit)
+}
+
+func (this Value) String() string {
+	if this.IsWin() {
+		return fmt.Sprintf("win in %v", this.Plies())
+	}
+	if this.IsLoss() {
+		return fmt.Sprintf("loss in %v", this.Plies())
+	}
+	return "draw"
+}
+
+// Table holds one value per position, indexed as in placement.index
+type Table struct {
+	Material Material
+	Data     []Value
+}
+
+func (this *Table) Stats() string {
+	wins, losses, longest := 0, 0, 0
+	for _, v := range this.Data {
+		if v.IsWin() {
+			wins++
+		} else if v.IsLoss() {
+			losses++
+		}
+		if v.Plies() > longest {
+			longest = v.Plies()
+		}
+	}
+	return fmt.Sprintf("%v wins, %v losses, longest %v plies", wins, losses, longest)
+}
+
+type Set struct {
+	tables map[string]*Table
+}
+
+func NewSet() *Set {
+	return &Set{tables: map[string]*Table{}}
+}
+
+func (this *Set) Add(t *Table) {
+	this.tables[t.Material.String()] = t
+}
+
+func (this *Set) Tables() []*Table {
+	output := []*Table{}
+	for _, t := range this.tables {
+		output = append(output, t)
+	}
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].Material.String() < output[j].Material.String()
+	})
+	return output
+}
+
+// lookup finds the value of the placement, trying the
+// color flipped table if there's none for the material
+func (this *Set) lookup(p placement) (Value, bool) {
+	p.canonical()
+	if t, ok := this.tables[p.material().String()]; ok {
+		return t.Data[p.index()], true
+	}
+	flip := p.flipped()
+	if t, ok := this.tables[flip.material().String()]; ok {
+		return t.Data[flip.index()], true
+	}
+	return Draw, false
+}
+
+// Probe finds the value of the position for the side to move
+func (this *Set) Probe(g *game.GameState) (Value, bool) {
+	if g.IsOver {
+		return Draw, false
+	}
+	p := placement{BlackTurn: g.BlackTurn}
+	for _, pieces := range [][]game.Slot{g.WhitePieces, g.BlackPieces} {
+		for _, slot := range pieces {
+			if slot.IsInvalid() {
+				continue
+			}
+			if p.N == MaxPieces {
+				return Draw, false
+			}
+			p.Pieces[p.N] = slot.Piece
+			p.Squares[p.N] = slot.Pos.Column + 8*slot.Pos.Row
+			p.N++
+		}
+	}
+	return this.lookup(p)
+}
+
+// Best picks the move that wins the fastest, or if there's
+// none, draws, or otherwise loses the slowest
+func (this *Set) Best(g *game.GameState) (game.Move, Value, bool) {
+	if _, ok := this.Probe(g); !ok {
+		return game.Move{}, Draw, false
+	}
+	var best game.Move
+	var bestValue Value
+	bestRank := -1 << 16
+	mvgen := movegen.NewMoveGenerator(g.Copy())
+	for _, mv := range movegen.ConsumeAll(mvgen) {
+		newG := g.Copy()
+		newG.Move(mv.From, mv.To)
+		var v Value
+		if newG.IsOver {
+			if newG.Result == rs.Draw {
+				v = Draw
+			} else {
+				v = win(1)
+			}
+		} else {
+			reply, ok := this.Probe(newG)
+			if !ok {
+				return game.Move{}, Draw, false
+			}
+			v = flip(reply)
+		}
+		if rank(v) > bestRank {
+			best = mv
+			bestValue = v
+			bestRank = rank(v)
+		}
+	}
+	return best, bestValue, bestRank != -1<<16
+}
+
+// flip turns the value of a reply into the value of the move
+func flip(v Value) Value {
+	if v.IsWin() {
+		return loss(v.Plies() + 1)
+	}
+	if v.IsLoss() {
+		return win(v.Plies() + 1)
+	}
+	return Draw
+}
+
+func rank(v Value) int {
+	if v.IsWin() {
+		return 1000 - v.Plies()
+	}
+	if v.IsLoss() {
+		return -1000 + v.Plies()
+	}
+	return 0
+}
+
+// score must stay below what the evaluators give for a
+// captured king, so that capturing it is still preferred
+const score = 9000
+
+// Score converts the value to a score as given by evaluators
+func Score(v Value, blackTurn bool) int {
+	output := 0
+	if v.IsWin() {
+		output = score - v.Plies()
+	} else if v.IsLoss() {
+		output = -score + v.Plies()
+	}
+	if blackTurn {
+		return -output
+	}
+	return output
+}
+
+// Evaluator uses the tables when it can, and eval otherwise
+func Evaluator(set *Set, eval ifaces.Evaluator) ifaces.Evaluator {
+	return func(g *game.GameState, depth int) int {
+		if v, ok := set.Probe(g); ok {
+			return Score(v, g.BlackTurn)
+		}
+		return eval(g, depth)
+	}
+}
+
+// Engine plays perfectly on positions covered by the
+// tables, and leaves the others to the fallback
+type Engine struct {
+	Name     string
+	Set      *Set
+	Fallback ifaces.Engine
+}
+
+var _ ifaces.Engine = &Engine{}
+
+func (this *Engine) Play(g *game.GameState) {
+	an := this.Analyse(g, ifaces.Limits{})
+	ok, _ := g.Move(an.Move.From, an.Move.To)
+	if !ok {
+		panic("engine made ilegal move")
+	}
+}
+
+func (this *Engine) Analyse(g *game.GameState, lim ifaces.Limits) ifaces.Analysis {
+	mv, v, ok := this.Set.Best(g)
+	if ok {
+		return ifaces.Analysis{
+			Move:   mv,
+			Score:  Score(v, g.BlackTurn),
+			Scored: true,
+			PV:     []game.Move{mv},
+		}
+	}
+	return this.Fallback.Analyse(g, lim)
+}
+
+func (this *Engine) String() string {
+	return this.Name
+}
+
+func (this *Engine) MaxDepth() int {
+	return ifaces.MaxDepth(this.Fallback)
+}
+
+// Wrap gives the engine access to the tables, both at the
+// root and, for our own engines, inside the search
+func Wrap(eng ifaces.Engine, set *Set) ifaces.Engine {
+	switch e := eng.(type) {
+	case *ifaces.BasicEngine:
+		copied := *e
+		copied.Eval = Evaluator(set, e.Eval)
+		eng = &copied
+	case *ifaces.IntermediateEngine:
+		copied := *e
+		copied.Eval = Evaluator(set, e.Eval)
+		eng = &copied
+	case *ifaces.TypeBEngine:
+		copied := *e
+		copied.Eval = Evaluator(set, e.Eval)
+		eng = &copied
+	}
+	return &Engine{
+		Name:     "tb+" + eng.String(),
+		Set:      set,
+		Fallback: eng,
+	}
+}
+
+// files are gzipped: a magic line, a line with the
+// material and then one byte for each position
+const magic = "TB1\n"
+
+const extension = ".tb"
+
+func (this *Table) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := gzip.NewWriter(f)
+	data := make([]byte, len(this.Data))
+	for i, v := range this.Data {
+		data[i] = byte(v)
+	}
+	_, err = w.Write([]byte(magic + this.Material.String() + "\n"))
+	if err == nil {
+		_, err = w.Write(data)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func LoadTable(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, errors.New(path + " is not a tablebase")
+	}
+	r := bufio.NewReader(gz)
+	header, err := r.ReadString('\n')
+	if err != nil || header != magic {
+		return nil, errors.New(path + " is not a tablebase")
+	}
+	name, err := r.ReadString('\n')
+	if err != nil {
+		return nil, errors.New(path + " is truncated")
+	}
+	m, err := ParseMaterial(strings.TrimSpace(name))
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, m.Positions())
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, errors.New(path + " is truncated")
+	}
+	t := &Table{Material: m, Data: make([]Value, len(data))}
+	for i, b := range data {
+		t.Data[i] = Value(b)
+	}
+	return t, nil
+}
+
+// Save writes every table of the set in the directory
+func (this *Set) Save(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	for _, t := range this.Tables() {
+		err = t.Save(filepath.Join(dir, t.Material.String()+extension))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load reads every table in the directory
+func Load(dir string) (*Set, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+extension))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no tablebases in " + dir)
+	}
+	set := NewSet()
+	for _, file := range files {
+		t, err := LoadTable(file)
+		if err != nil {
+			return nil, err
+		}
+		set.Add(t)
+	}
+	return set, nil
+}